@@ -0,0 +1,58 @@
+package hotp
+
+import "sync"
+
+/*
+** counts how often each drift was seen across many validations, e.g. one tracker for a fleet of
+** hardware tokens, to find the ones that keep drifting and need a resync. Safe for concurrent use,
+** so the same tracker can be shared by many hotp objects, see SetDriftTracker
+ */
+type DriftTracker struct {
+	mu        sync.Mutex
+	histogram map[int]int
+}
+
+func NewDriftTracker() *DriftTracker {
+	return &DriftTracker{histogram: map[int]int{}}
+}
+
+// counts one validation that matched delta counters away, negative when the client was behind
+func (tracker *DriftTracker) Record(delta int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.histogram == nil {
+		tracker.histogram = map[int]int{}
+	}
+
+	tracker.histogram[delta]++
+}
+
+// a copy of how many validations were recorded for each delta
+func (tracker *DriftTracker) Histogram() map[int]int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	histogram := make(map[int]int, len(tracker.histogram))
+	for delta, count := range tracker.histogram {
+		histogram[delta] = count
+	}
+
+	return histogram
+}
+
+// forgets everything recorded so far
+func (tracker *DriftTracker) Reset() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	clear(tracker.histogram)
+}
+
+/*
+** records the signed delta of every code ValidateWithDelta accepts in tracker. nil stops recording.
+** Rejected codes aren't recorded, there is no delta for those
+ */
+func (hotp *Hotp) SetDriftTracker(tracker *DriftTracker) {
+	hotp.driftTracker = tracker
+}
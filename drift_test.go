@@ -0,0 +1,92 @@
+package hotp
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftTracker(t *testing.T) {
+	tracker := NewDriftTracker()
+	assert.Empty(t, tracker.Histogram())
+
+	for _, delta := range []int{0, 0, 1, 3, -1, 0, 3} {
+		tracker.Record(delta)
+	}
+
+	histogram := tracker.Histogram()
+	assert.Equal(t, map[int]int{-1: 1, 0: 3, 1: 1, 3: 2}, histogram)
+
+	// the histogram is a copy
+	histogram[0] = 100
+	assert.Equal(t, 3, tracker.Histogram()[0])
+
+	tracker.Reset()
+	assert.Empty(t, tracker.Histogram())
+
+	// the zero value works too
+	var zero DriftTracker
+	zero.Record(2)
+	assert.Equal(t, map[int]int{2: 1}, zero.Histogram())
+}
+
+func TestDriftTrackerConcurrent(t *testing.T) {
+	tracker := NewDriftTracker()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range 100 {
+				tracker.Record(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[int]int{1: 800}, tracker.Histogram())
+}
+
+func TestValidateWithDeltaDriftTracker(t *testing.T) {
+	tracker := NewDriftTracker()
+
+	hotp := CreateHotp(secret, 5, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+	assert.Nil(t, hotp.SetLookBehindWindow(2))
+	hotp.SetDriftTracker(tracker)
+
+	// counter 4, one behind 5
+	validated, delta, err := hotp.ValidateWithDelta(338314)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(0), delta)
+
+	// counter 5, exactly the current one
+	validated, _, err = hotp.ValidateWithDelta(254676)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// counter 8, two ahead of 6
+	validated, _, err = hotp.ValidateWithDelta(399871)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// rejected codes aren't recorded
+	validated, _, err = hotp.ValidateWithDelta(0)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	assert.Equal(t, map[int]int{-1: 1, 0: 1, 2: 1}, tracker.Histogram())
+
+	// the other validate methods leave it alone
+	_, err = hotp.Validate(0)
+	assert.Nil(t, err)
+
+	hotp.SetDriftTracker(nil)
+	_, _, err = hotp.ValidateWithDelta(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tracker.Histogram()))
+}
@@ -0,0 +1,231 @@
+package hotp
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+)
+
+// turns the 31 bits left after dynamic truncation into the code shown to the user
+type Encoder interface {
+	Encode(sbits int32) string
+}
+
+// adapts a plain function to an Encoder
+type EncoderFunc func(sbits int32) string
+
+func (f EncoderFunc) Encode(sbits int32) string {
+	return f(sbits)
+}
+
+// the rfc4226 encoding, Sbits mod 10^Digits padded out with leading zeros
+type DecimalEncoder struct {
+	Digits int
+}
+
+// digits outside of 1 to 9 return "", which codeWith reports instead of comparing
+func (encoder DecimalEncoder) Encode(sbits int32) string {
+	if validateDigits(encoder.Digits) != nil {
+		return ""
+	}
+
+	return formatCode(reduce(sbits, encoder.Digits), encoder.Digits)
+}
+
+/*
+** writes the truncated value in base len(Alphabet), most significant character first and padded out
+** with the first character, keeping the last Length characters. With "0123456789" this is the
+** DecimalEncoder. Alphabet needs at least 2 distinct characters, see WithAlphabet
+ */
+type AlphabetEncoder struct {
+	Alphabet string
+	Length   int
+}
+
+// an invalid encoder, e.g. an empty Alphabet, returns "", which codeWith reports instead of comparing
+func (encoder AlphabetEncoder) Encode(sbits int32) string {
+	alphabet := []rune(encoder.Alphabet)
+	base := uint32(len(alphabet))
+	if base == 0 || encoder.Length < 1 {
+		return ""
+	}
+
+	// the truncated value is 31 bits, so it is never negative
+	value := uint32(sbits)
+	code := make([]rune, encoder.Length)
+	for i := len(code) - 1; i >= 0; i-- {
+		code[i] = alphabet[value%base]
+		value /= base
+	}
+
+	return string(code)
+}
+
+func (encoder AlphabetEncoder) validate() error {
+	alphabet := []rune(encoder.Alphabet)
+	if len(alphabet) < 2 {
+		return fmt.Errorf("%w: needs at least 2 characters. Got: %d", ErrInvalidAlphabet, len(alphabet))
+	}
+
+	seen := map[rune]bool{}
+	for _, r := range alphabet {
+		if seen[r] {
+			return fmt.Errorf("%w: '%c' is repeated", ErrInvalidAlphabet, r)
+		}
+
+		seen[r] = true
+	}
+
+	if encoder.Length < 1 {
+		return fmt.Errorf("%w: length has to be at least 1. Got: %d", ErrInvalidAlphabet, encoder.Length)
+	}
+
+	return nil
+}
+
+// the 5 character Steam Guard encoding, see CalculateSteam
+type SteamEncoder struct{}
+
+func (SteamEncoder) Encode(sbits int32) string {
+	return encodeSteam(sbits)
+}
+
+// same as CalculateCode, but the truncated value is turned into a code by the encoder instead of mod 10^digits
+func CalculateCodeWithEncoder(secret string, counter uint64, hasher func() hash.Hash, encoder Encoder) (string, error) {
+	Sbits, err := dynamicTruncate([]byte(secret), counter, hasher)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeChecked(encoder, Sbits)
+}
+
+// encodes Sbits, rejecting an empty code so it can never be compared against an empty input
+func encodeChecked(encoder Encoder, Sbits int32) (string, error) {
+	code := encoder.Encode(Sbits)
+	if code == "" {
+		return "", fmt.Errorf("%w: the encoder returned an empty code", ErrInvalidCode)
+	}
+
+	return code, nil
+}
+
+// checks the encoders this package knows how to validate, anything else is trusted as is
+func validateEncoder(encoder Encoder) error {
+	switch encoder := encoder.(type) {
+	case AlphabetEncoder:
+		return encoder.validate()
+	case DecimalEncoder:
+		return validateDigits(encoder.Digits)
+	}
+
+	return nil
+}
+
+/*
+** sets the encoder used by Calculate, CalculateRange and the validate methods. nil goes back to the
+** decimal codes of digits length. Validate formats its int as a decimal code, so use ValidateString
+** for anything else. CalculateInt is always decimal. An invalid AlphabetEncoder or DecimalEncoder is rejected
+ */
+func (hotp *Hotp) SetEncoder(encoder Encoder) error {
+	err := validateEncoder(encoder)
+	if err != nil {
+		return err
+	}
+
+	hotp.encoder = encoder
+	return nil
+}
+
+// the truncated value for counter, from the fixed offset when one is set. A nil mac keys a new hmac
+func (hotp Hotp) sbits(counter uint64, mac hash.Hash) (int32, error) {
+	if hotp.isZeroized() {
+		return -1, ErrZeroized
+	}
+
+	offset := dynamicOffset
+	if hotp.fixedOffset {
+		offset = hotp.truncationOffset
+	}
+
+	if mac == nil {
+		return truncate(hotp.secret, counter, hotp.hasher, offset, hotp.counterBytes)
+	}
+
+	return truncateMac(mac, counter, offset, hotp.counterBytes)
+}
+
+// the hmac keyedMac caches, with what it was keyed from as plain copies sharing it can change either
+type cachedMac struct {
+	hash     hash.Hash
+	secret   []byte
+	hashFunc HashFunc
+}
+
+// whether the cached hmac was keyed from this very secret buffer with this algorithm
+func (cache cachedMac) keyedFor(secret []byte, hashFunc HashFunc) bool {
+	return cache.hash != nil && len(cache.secret) == len(secret) && &cache.secret[0] == &secret[0] && cache.hashFunc == hashFunc
+}
+
+/*
+** keying an hmac hashes the secret into its inner and outer pads, which is about half the work of a
+** code. The validate methods run with mu held, so they key it once and reset it for every counter,
+** roughly doubling their throughput (BenchmarkValidateKeyedMac vs BenchmarkValidateStateless).
+** Must be called with mu held. nil for an empty secret, so sbits reports it
+ */
+func (hotp *Hotp) keyedMac() hash.Hash {
+	if len(hotp.secret) == 0 {
+		return nil
+	}
+
+	// only the zero value gets here without one
+	if hotp.mac == nil {
+		hotp.mac = &cachedMac{}
+	}
+
+	if !hotp.mac.keyedFor(hotp.secret, hotp.hashFunc) {
+		*hotp.mac = cachedMac{
+			hash:     hmac.New(hotp.hasher, hotp.secret),
+			secret:   hotp.secret,
+			hashFunc: hotp.hashFunc,
+		}
+	}
+
+	return hotp.mac.hash
+}
+
+// the code for counter, with the encoder when one is set
+func (hotp Hotp) codeAt(counter uint64) (string, error) {
+	return hotp.codeWith(counter, nil)
+}
+
+// same as codeAt, reusing mac when it isn't nil
+func (hotp Hotp) codeWith(counter uint64, mac hash.Hash) (string, error) {
+	encoder := hotp.encoder
+	if encoder == nil {
+		err := validateDigits(hotp.digits)
+		if err != nil {
+			return "", err
+		}
+
+		encoder = DecimalEncoder{Digits: hotp.digits}
+	}
+
+	Sbits, err := hotp.sbits(counter, mac)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeChecked(encoder, Sbits)
+}
+
+// compares the code for counter with code in constant time. Must be called with mu held
+func (hotp *Hotp) matches(counter uint64, code string) (bool, error) {
+	correctCode, err := hotp.codeWith(counter, hotp.keyedMac())
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(correctCode), []byte(code)) == 1, nil
+}
@@ -0,0 +1,195 @@
+package hotp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the truncated values from rfc4226 appendix D as 8 hex characters
+var hexEncodedCodes = []string{
+	"4c93cf18",
+	"41397eea",
+	"082fef30",
+	"66ef7655",
+	"61c5938a",
+	"33c083d4",
+	"7256c032",
+	"04e5b397",
+	"2823443f",
+	"2679dc69",
+}
+
+var hexEncoder = EncoderFunc(func(sbits int32) string {
+	return fmt.Sprintf("%08x", sbits)
+})
+
+func TestCalculateCodeWithEncoder(t *testing.T) {
+	for counter, expected := range hexEncodedCodes {
+		code, err := CalculateCodeWithEncoder(secret, uint64(counter), sha1.New, hexEncoder)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code, "counter %d", counter)
+	}
+
+	// the decimal encoder is the same as CalculateCode
+	for counter := range uint64(10) {
+		expected, err := CalculateCode(secret, counter, 6, sha1.New)
+		assert.Nil(t, err)
+
+		code, err := CalculateCodeWithEncoder(secret, counter, sha1.New, DecimalEncoder{Digits: 6})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	_, err := CalculateCodeWithEncoder("", 0, sha1.New, hexEncoder)
+	assert.ErrorIs(t, err, ErrEmptySecret)
+}
+
+func TestHotpEncoder(t *testing.T) {
+	hotp, err := NewHotp(secret, WithEncoder(hexEncoder), WithLookAhead(2))
+	assert.Nil(t, err)
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, hexEncodedCodes[0], code)
+
+	codes, err := hotp.CalculateRange(3)
+	assert.Nil(t, err)
+	assert.Equal(t, hexEncodedCodes[:3], codes)
+
+	validated, err := hotp.ValidateString(hexEncodedCodes[2])
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(3), hotp.GetCounter())
+
+	// back to decimal codes
+	assert.Nil(t, hotp.SetEncoder(nil))
+	code, err = hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "969429", code)
+
+	steam := CreateHotp(secret, 0, 6, "")
+	expected, err := steam.CalculateSteam()
+	assert.Nil(t, err)
+
+	assert.Nil(t, steam.SetEncoder(SteamEncoder{}))
+	code, err = steam.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+}
+
+// the truncated values from rfc4226 appendix D in base 36
+var base36Codes = []string{
+	"L8WRH4",
+	"I3IDBE",
+	"29S300",
+	"SK6Y51",
+	"R4M556",
+	"ECXPLG",
+	"VQ3KFM",
+	"1CX11Z",
+	"B4XANZ",
+	"AOBQSP",
+}
+
+const base36Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func TestAlphabetEncoder(t *testing.T) {
+	for counter, expected := range base36Codes {
+		code, err := CalculateCodeWithEncoder(secret, uint64(counter), sha1.New, AlphabetEncoder{Alphabet: base36Alphabet, Length: 6})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code, "counter %d", counter)
+	}
+
+	// shorter codes keep the least significant characters
+	code, err := CalculateCodeWithEncoder(secret, 0, sha1.New, AlphabetEncoder{Alphabet: base36Alphabet, Length: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, "WRH4", code)
+
+	// the decimal alphabet is the same as DecimalEncoder
+	for counter := range uint64(10) {
+		expected, err := CalculateCodeWithEncoder(secret, counter, sha1.New, DecimalEncoder{Digits: 6})
+		assert.Nil(t, err)
+
+		code, err := CalculateCodeWithEncoder(secret, counter, sha1.New, AlphabetEncoder{Alphabet: "0123456789", Length: 6})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestWithAlphabet(t *testing.T) {
+	hotp, err := NewHotp(secret, WithAlphabet(base36Alphabet, 6), WithLookAhead(2))
+	assert.Nil(t, err)
+
+	codes, err := hotp.CalculateRange(3)
+	assert.Nil(t, err)
+	assert.Equal(t, base36Codes[:3], codes)
+
+	validated, err := hotp.ValidateString(base36Codes[1])
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(2), hotp.GetCounter())
+
+	_, err = NewHotp(secret, WithAlphabet("0", 6))
+	assert.ErrorIs(t, err, ErrInvalidAlphabet)
+
+	_, err = NewHotp(secret, WithAlphabet("0120", 6))
+	assert.ErrorIs(t, err, ErrInvalidAlphabet)
+
+	_, err = NewHotp(secret, WithAlphabet(base36Alphabet, 0))
+	assert.ErrorIs(t, err, ErrInvalidAlphabet)
+}
+
+func TestSetEncoderInvalidAlphabet(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	for _, encoder := range []AlphabetEncoder{{}, {Length: 6}, {Alphabet: "0", Length: 6}, {Alphabet: base36Alphabet, Length: -1}} {
+		assert.ErrorIs(t, hotp.SetEncoder(encoder), ErrInvalidAlphabet)
+
+		assert.NotPanics(t, func() { encoder.Encode(1284755224) })
+	}
+
+	// an empty code is an error rather than something to compare against
+	_, err := CalculateCodeWithEncoder(secret, 0, sha1.New, AlphabetEncoder{Length: 6})
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	// still decimal
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+
+	// an encoder that slipped past validation can't validate an empty code
+	hotp.encoder = AlphabetEncoder{}
+	_, err = hotp.Calculate()
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	validated, err := hotp.ValidateString("")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	assert.False(t, validated)
+
+	emptyEncoder := EncoderFunc(func(int32) string { return "" })
+	assert.Nil(t, hotp.SetEncoder(emptyEncoder))
+	validated, err = hotp.ValidateString("")
+	assert.NotNil(t, err)
+	assert.False(t, validated)
+}
+
+func TestInvalidDecimalEncoder(t *testing.T) {
+	for _, digits := range []int{-1, 0, maxDigits + 1} {
+		encoder := DecimalEncoder{Digits: digits}
+
+		_, err := NewHotp(secret, WithEncoder(encoder))
+		assert.ErrorIs(t, err, ErrInvalidDigits, digits)
+
+		hotp := CreateHotp(secret, 0, 6, "")
+		assert.ErrorIs(t, hotp.SetEncoder(encoder), ErrInvalidDigits, digits)
+
+		assert.NotPanics(t, func() { encoder.Encode(1284755224) })
+		_, err = CalculateCodeWithEncoder(secret, 0, sha1.New, encoder)
+		assert.ErrorIs(t, err, ErrInvalidCode, digits)
+	}
+
+	assert.NotPanics(t, func() { reduce(1284755224, -1) })
+}
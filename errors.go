@@ -0,0 +1,29 @@
+package hotp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the errors returned by this package wrap one of these, so callers can branch on them with errors.Is
+var (
+	ErrEmptySecret      = errors.New("secret must not be empty")
+	ErrZeroized         = errors.New("the secret has been zeroized")
+	ErrInvalidSecret    = errors.New("secret is not valid")
+	ErrSecretTooShort   = fmt.Errorf("secret length has to be at least %d bytes", minSecretLength)
+	ErrWeakSecret       = errors.New("could not generate a secret that isn't a single repeated byte")
+	ErrInvalidDigits    = fmt.Errorf("digits has to be >= %d and <= %d", minDigits, maxDigits)
+	ErrInvalidCode      = errors.New("code is not valid")
+	ErrInvalidAlphabet  = errors.New("alphabet is not valid")
+	ErrUnsupportedHash  = errors.New("hashing function not implemented")
+	ErrWindowTooLarge   = fmt.Errorf("window size cannot be greater than %d", maxLookAheadSize)
+	ErrNegativeWindow   = errors.New("window size cannot be negative")
+	ErrLockedOut        = errors.New("too many failed attempts, validation is locked out until the failures are reset")
+	ErrResyncPending    = errors.New("the code is ahead of the counter, the next code is needed to resynchronize")
+	ErrCounterExhausted = errors.New("counter is exhausted. Please rotate the secret")
+	ErrInvalidTimeStep  = errors.New("time step has to be at least 1 second")
+	ErrInvalidURI       = errors.New("otpauth uri is not valid")
+	ErrIssuerMismatch   = errors.New("the label issuer and the issuer parameter differ")
+	ErrInvalidQRLevel   = errors.New("qr error correction level is not valid")
+	ErrInvalidQRSize    = errors.New("qr module size has to be at least 1 pixel")
+)
@@ -0,0 +1,64 @@
+package hotp
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	_, err := CalculateCodeWith("", 0, 6, SHA1)
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+
+	_, err = CreateHotpChecked("", 0, 6, "")
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+
+	_, err = NewHotp("")
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+
+	_, err = NormalizeSecret("  ")
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+
+	_, err = NormalizeSecret("GEZDGNBV!Y3TQOJQ")
+	assert.True(t, errors.Is(err, ErrInvalidSecret))
+
+	_, err = CreateHotpChecked(secret, 0, 10, "")
+	assert.True(t, errors.Is(err, ErrInvalidDigits))
+
+	_, err = NewHotp(secret, WithDigits(0))
+	assert.True(t, errors.Is(err, ErrInvalidDigits))
+
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.True(t, errors.Is(hotp.SetLookAheadWindow(maxLookAheadSize+1), ErrWindowTooLarge))
+	assert.True(t, errors.Is(hotp.SetLookBehindWindow(maxLookAheadSize+1), ErrWindowTooLarge))
+	assert.True(t, errors.Is(hotp.SetResyncWindow(maxLookAheadSize+1), ErrWindowTooLarge))
+
+	totp := CreateTotp(secret, 6)
+	assert.True(t, errors.Is(totp.SetSkew(maxLookAheadSize+1), ErrWindowTooLarge))
+	assert.True(t, errors.Is(totp.SetTimeStep(0), ErrInvalidTimeStep))
+
+	assert.True(t, errors.Is(hotp.SetHashFunc(HashFunc("md5")), ErrUnsupportedHash))
+	_, err = ValidateTotp(secret, 0, 6, HashFunc("md5"), 30, 0)
+	assert.True(t, errors.Is(err, ErrUnsupportedHash))
+
+	hotp.SetCounter(math.MaxUint64)
+	assert.True(t, errors.Is(hotp.IncrementCounterChecked(), ErrCounterExhausted))
+	_, err = hotp.Validate(0)
+	assert.True(t, errors.Is(err, ErrCounterExhausted))
+
+	_, err = GenerateSecretChecked(minSecretLength - 1)
+	assert.True(t, errors.Is(err, ErrSecretTooShort))
+
+	_, err = ParseOtpAuthURI("otpauth://totp/label?secret=GEZDGNBVGY3TQOJQ")
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+
+	_, err = ParseOtpAuthURI("otpauth://hotp/label?digits=6")
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+
+	_, err = ParseOtpAuthURI("otpauth://hotp/label?secret=GEZDGNBVGY3TQOJQ&digits=12")
+	assert.True(t, errors.Is(err, ErrInvalidDigits))
+
+	assert.True(t, errors.Is(hotp.SyncCounterToTime(0), ErrInvalidTimeStep))
+}
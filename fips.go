@@ -0,0 +1,50 @@
+package hotp
+
+import (
+	"fmt"
+	"hash"
+	"sync/atomic"
+)
+
+// when set, SHA-1 is refused for new tokens, see SetFIPSMode
+var fipsMode atomic.Bool
+
+/*
+** FIPS deployments discourage HMAC-SHA1 for new tokens. With FIPS mode on, SetHashFunc, the checked
+** constructors and parsers, and every function taking the algorithm per call (CalculateUsing,
+** ValidateMultiAlgo, CalculateCodeWith, DynamicTruncate and ValidateTotp) refuse SHA1 with an error
+** wrapping ErrUnsupportedHash, so CreateHotpChecked fails and CreateHotpFIPS (SHA256) has to be used
+** instead. Existing tokens keep working: RestoreHotp and UnmarshalJSON restore SHA1 state as is.
+** CreateHotp can't return an error, and the functions taking a hash constructor can't tell it is SHA1,
+** so they aren't guarded
+ */
+func SetFIPSMode(enabled bool) {
+	fipsMode.Store(enabled)
+}
+
+func IsFIPSMode() bool {
+	return fipsMode.Load()
+}
+
+func checkFIPS(hashFunc HashFunc) error {
+	if hashFunc == SHA1 && fipsMode.Load() {
+		return fmt.Errorf("%w: '%s' is not allowed in FIPS mode", ErrUnsupportedHash, hashFunc)
+	}
+
+	return nil
+}
+
+// the hasher for an algorithm chosen per call, refused in FIPS mode the same way SetHashFunc does
+func hasherForCall(algorithm HashFunc) (func() hash.Hash, error) {
+	err := checkFIPS(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return hasherFor(algorithm)
+}
+
+// same as CreateHotpChecked, but defaults to SHA256 so it can be used in FIPS mode
+func CreateHotpFIPS(secret string, counter uint64, digits int, label string) (Hotp, error) {
+	return createHotpChecked(secret, counter, digits, label, SHA256)
+}
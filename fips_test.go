@@ -0,0 +1,106 @@
+package hotp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIPSMode(t *testing.T) {
+	defer SetFIPSMode(false)
+
+	// allowed by default
+	assert.False(t, IsFIPSMode())
+	_, err := CreateHotpChecked(secret, 0, 6, "")
+	assert.Nil(t, err)
+
+	SetFIPSMode(true)
+	assert.True(t, IsFIPSMode())
+
+	_, err = CreateHotpChecked(secret, 0, 6, "")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = NewHotp(secret)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	hotp, err := NewHotp(secret, WithHashFunc(SHA512))
+	assert.Nil(t, err)
+	assert.ErrorIs(t, hotp.SetHashFunc(SHA1), ErrUnsupportedHash)
+	assert.ErrorIs(t, hotp.SetHashFunc(HashFunc("SHA-1")), ErrUnsupportedHash)
+	assert.Equal(t, SHA512, hotp.GetHashFunc())
+
+	fips, err := CreateHotpFIPS(secret, 0, 6, "")
+	assert.Nil(t, err)
+	assert.Equal(t, SHA256, fips.GetHashFunc())
+
+	// uris without an algorithm are SHA1
+	_, err = ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA256")
+	assert.Nil(t, err)
+
+	_, err = ParseTotpAuthURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	totp := CreateTotp(secret, 6)
+	assert.ErrorIs(t, totp.SetHashFunc(SHA1), ErrUnsupportedHash)
+	assert.Nil(t, totp.SetHashFunc(SHA256))
+
+	SetFIPSMode(false)
+	assert.Nil(t, hotp.SetHashFunc(SHA1))
+}
+
+func TestFIPSModeExistingTokens(t *testing.T) {
+	defer SetFIPSMode(false)
+
+	existing := CreateHotp(secret, 3, 6, "alice")
+	state := existing.Snapshot()
+	data, err := json.Marshal(&existing)
+	assert.Nil(t, err)
+
+	SetFIPSMode(true)
+
+	// tokens persisted before FIPS mode was turned on still restore, and keep their algorithm
+	restored, err := RestoreHotp(state)
+	assert.Nil(t, err)
+	assert.Equal(t, SHA1, restored.GetHashFunc())
+
+	var unmarshaled Hotp
+	assert.Nil(t, json.Unmarshal(data, &unmarshaled))
+	assert.Equal(t, SHA1, unmarshaled.GetHashFunc())
+
+	validated, err := restored.Validate(969429) // counter 3
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
+
+func TestFIPSModePerCallAlgorithm(t *testing.T) {
+	defer SetFIPSMode(false)
+	SetFIPSMode(true)
+
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	_, err := hotp.CalculateUsing(SHA1)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	_, err = hotp.CalculateUsing(HashFunc("sha-1"))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, _, err = hotp.ValidateMultiAlgo(755224, []HashFunc{SHA256, SHA1})
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = CalculateCodeWith(secret, 0, 6, SHA1)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = DynamicTruncate(secret, 0, SHA1)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = ValidateTotp(secret, 755224, 6, SHA1, 30, 0)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = hotp.CalculateUsing(SHA256)
+	assert.Nil(t, err)
+	_, err = CalculateCodeWith(secret, 0, 6, SHA512)
+	assert.Nil(t, err)
+}
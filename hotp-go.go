@@ -1,70 +1,192 @@
 package hotp
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"hash"
+	"io"
 	"math"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
 	maxLookAheadSize = 10
+	maxRangeSize     = 100
+	minSecretLength  = 16
+	minHashSize      = 20
+	minDigits        = 1
+	maxDigits        = 9 // Sbits is 31 bits, at most 2147483647, so a 10th digit could only ever be 0, 1 or 2
+	maxOffset        = 15
+	dynamicOffset    = -1
 	SHA1             = HashFunc("sha1")
 	SHA256           = HashFunc("sha256")
 	SHA512           = HashFunc("sha512")
+	// the secret length in bytes recommended by rfc4226
+	DefaultSecretLength = 20
+	// how many times GenerateSecretChecked regenerates a weak secret before giving up
+	maxSecretAttempts = 3
+	// the counter is 8 bytes in rfc4226, some legacy tokens use 4
+	defaultCounterBytes = 8
+	legacyCounterBytes  = 4
+	// the shortest code ValidateFlexibleDigits retries at, rfc4226 R-4 requires at least 6 digits
+	minFlexibleDigits = 6
 )
 
 type HashFunc string
 
+// how a secret string is encoded, see DecodeSecretAs
+type SecretEncoding string
+
+const (
+	Base32 = SecretEncoding("base32")
+	Hex    = SecretEncoding("hex")
+	Raw    = SecretEncoding("raw")
+)
+
 var (
 	issuer = ""
+	// secrets are encoded without padding, as most authenticator apps expect
+	base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	// the random source for GenerateSecretChecked, replaced in tests
+	randReader io.Reader = rand.Reader
+
+	// extra hashing functions added with RegisterHashFunc
+	hashRegistry   = map[HashFunc]func() hash.Hash{}
+	hashRegistryMu sync.RWMutex
 )
 
 func init() {
+	issuer = issuerFromEnv()
+}
+
+// reads the ISSUER env, falling back to "hotp" when it is unset or empty
+func issuerFromEnv() string {
 	envIssuer := os.Getenv("ISSUER")
 	if envIssuer == "" {
-		issuer = "hotp"
+		return "hotp"
 	}
 
-	issuer = envIssuer
+	return envIssuer
 }
 
 type Hotp struct {
-	secret          string
-	counter         uint64
-	digits          int
-	lookAheadWindow int
-	hashFunc        HashFunc
-	label           string
-	hasher          func() hash.Hash
+	secret           []byte
+	counter          uint64
+	digits           int
+	lookAheadWindow  int
+	lookBehindWindow int
+	resyncWindow     int
+	hashFunc         HashFunc
+	label            string
+	issuer           string
+	omitIssuerLabel  bool
+	hexCounter       bool
+	hasher           func() hash.Hash
+	// set by WithHasher, the hasher didn't come from hashFunc
+	customHasher bool
+	// nil means decimal codes of digits length
+	encoder Encoder
+	// only used when fixedOffset is true, see SetTruncationOffset
+	truncationOffset int
+	fixedOffset      bool
+	// how many bytes the counter is written as, 0 is the rfc4226 8. See SetCounterBytes
+	counterBytes int
+	// see OnValidateSuccess and OnValidateFailure
+	onSuccess func(delta uint64)
+	onFailure func()
+	// see SetDriftTracker
+	driftTracker *DriftTracker
+	// consecutive rejected codes, validation is locked out once it reaches maxFailures. 0 is no limit
+	failures    int
+	maxFailures int
+	// the counter a code matched ahead at, waiting for the code after it. See EnableTwoStepResync
+	twoStepResync    bool
+	pendingResync    uint64
+	hasPendingResync bool
+	// the highest counter a code was accepted at, only set once hasValidated is true
+	lastValidatedCounter uint64
+	hasValidated         bool
+	// the counter is left alone on success, see SetAutoAdvance
+	manualCounter bool
+	// the keyed hmac reused by the validate methods, see keyedMac. Only used with mu held. A pointer shared
+	// with plain copies like zeroized, so Zeroize can drop it for all of them
+	mac *cachedMac
+	// set for good by Zeroize. A pointer shared with plain copies, as they share the secret it wipes
+	zeroized *atomic.Bool
+	// the first option NewHotp couldn't apply, see WithCounterFromTime
+	optionErr error
+	// guards the counter for the pointer receiver methods, the value receiver ones aren't covered, see GetCounter.
+	// A pointer so copies of the object can still be passed around by value.
+	// nil in the zero value, use mutex() rather than reading it directly
+	mu *sync.Mutex
+}
+
+// the mutex, created on first use for a zero value Hotp, which the constructors never return
+func (hotp *Hotp) mutex() *sync.Mutex {
+	if hotp.mu == nil {
+		hotp.mu = &sync.Mutex{}
+	}
+
+	return hotp.mu
+}
+
+func dynamicTruncate(secret []byte, counter uint64, hasher func() hash.Hash) (int32, error) {
+	return truncate(secret, counter, hasher, dynamicOffset, defaultCounterBytes)
+}
+
+// same as dynamicTruncate, but reads the 4 bytes from a fixed offset unless it is dynamicOffset
+func truncate(secret []byte, counter uint64, hasher func() hash.Hash, fixedOffset int, counterBytes int) (int32, error) {
+	// an empty key still produces an hmac, so a secret that failed to load would otherwise go unnoticed
+	if len(secret) == 0 {
+		return -1, ErrEmptySecret
+	}
+
+	return truncateMac(hmac.New(hasher, secret), counter, fixedOffset, counterBytes)
 }
 
-func dynamicTruncate(secret string, counter uint64, hasher func() hash.Hash) (int32, error) {
-	hmac := hmac.New(hasher, []byte(secret))
+// truncates with an already keyed hmac, which is reset first so it can be reused across counters
+func truncateMac(mac hash.Hash, counter uint64, fixedOffset int, counterBytes int) (int32, error) {
+	bigEndCount, err := counterMessage(counter, counterBytes)
+	if err != nil {
+		return -1, err
+	}
 
-	// a uint64 is 8 bytes
-	bigEndCount := make([]byte, 8)
-	binary.BigEndian.PutUint64(bigEndCount, counter)
+	mac.Reset()
 
-	_, err := hmac.Write(bigEndCount)
+	_, err = mac.Write(bigEndCount)
 	if err != nil {
 		return -1, err
 	}
 
-	hash := hmac.Sum(nil)
+	hash := mac.Sum(nil)
 
-	offsetBits := hash[0 : 19+1]
+	// the offset can be up to 15 and 4 bytes are read from it, so anything shorter than SHA-1's 20 bytes
+	// could be indexed out of bounds. SHA-256 and SHA-512 are longer, but a registered hash might not be
+	if len(hash) < minHashSize {
+		return -1, fmt.Errorf("%w: hmac has to be at least %d bytes for dynamic truncation. Got: %d", ErrUnsupportedHash, minHashSize, len(hash))
+	}
 
-	offset := int(offsetBits[19]) & 0xf
-	if offset < 0 || offset > 15 {
-		panic(fmt.Sprintf("offset has to be >= 0 and <= 15. Got: %d", offset))
+	// the offset is the low-order 4 bits of the last byte of the hmac
+	offset := int(hash[len(hash)-1]) & 0xf
+	if fixedOffset != dynamicOffset {
+		offset = fixedOffset
 	}
 
 	P := hash[offset : offset+3+1]
@@ -77,6 +199,37 @@ func dynamicTruncate(secret string, counter uint64, hasher func() hash.Hash) (in
 	return int32(a<<24 | b<<16 | c<<8 | d), nil
 }
 
+// the counter as the big endian moving factor, 8 bytes as in rfc4226 or 4 for some legacy tokens
+func counterMessage(counter uint64, counterBytes int) ([]byte, error) {
+	if counterBytes != legacyCounterBytes {
+		bigEndCount := make([]byte, defaultCounterBytes)
+		binary.BigEndian.PutUint64(bigEndCount, counter)
+		return bigEndCount, nil
+	}
+
+	if counter > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: %d doesn't fit in a %d byte counter", ErrCounterExhausted, counter, legacyCounterBytes)
+	}
+
+	bigEndCount := make([]byte, legacyCounterBytes)
+	binary.BigEndian.PutUint32(bigEndCount, uint32(counter))
+	return bigEndCount, nil
+}
+
+/*
+** returns Sbits, the 31 bit value rfc4226 section 5.3 gets from dynamic truncation, before it is
+** reduced to a code with mod 10^digits. Useful for custom encodings or checking against the
+** Truncated column of the rfc4226 appendix D vectors
+ */
+func DynamicTruncate(secret string, counter uint64, algorithm HashFunc) (int32, error) {
+	hasher, err := hasherForCall(algorithm)
+	if err != nil {
+		return -1, err
+	}
+
+	return dynamicTruncate([]byte(secret), counter, hasher)
+}
+
 func formatCode(code int, digits int) string {
 	// pad out the string if the leading number(s) are a 0
 	format := fmt.Sprintf(`%%0%dd`, digits)
@@ -84,29 +237,110 @@ func formatCode(code int, digits int) string {
 	return fmt.Sprintf(format, code)
 }
 
-// can be used directly without needing to construct an Hotp object
-func CalculateCode(secret string, counter uint64, digits int, hasher func() hash.Hash) (string, error) {
+// Steam Guard codes are 5 characters from this alphabet instead of decimal digits
+const (
+	steamAlphabet   = "23456789BCDFGHJKMNPQRTVWXY"
+	steamCodeLength = 5
+)
+
+// maps Sbits onto the Steam alphabet, least significant character first
+func encodeSteam(Sbits int32) string {
+	code := make([]byte, steamCodeLength)
+	base := int32(len(steamAlphabet))
+
+	for i := range code {
+		code[i] = steamAlphabet[Sbits%base]
+		Sbits /= base
+	}
+
+	return string(code)
+}
+
+func validateDigits(digits int) error {
+	if digits < minDigits || digits > maxDigits {
+		return fmt.Errorf("%w. Got: %d", ErrInvalidDigits, digits)
+	}
+
+	return nil
+}
+
+/*
+** Sbits mod 10^digits. Sbits has its top bit cleared by dynamic truncation so it is never negative,
+** but Go's % keeps the sign of the dividend, so a negative value is moved back into [0, 10^digits)
+** rather than relying on that. Done in int64, so 10^9 for maxDigits has room to spare and a digits
+** past it can't overflow the modulo even though validateDigits rejects those first
+ */
+func reduce(Sbits int32, digits int) int {
+	// a negative digits would round the modulo down to 0
+	modulo := max(int64(math.Pow10(digits)), 1)
+
+	code := int64(Sbits) % modulo
+	if code < 0 {
+		code += modulo
+	}
+
+	return int(code)
+}
+
+// the code before it is padded out to digits
+func calculateInt(secret []byte, counter uint64, digits int, hasher func() hash.Hash) (int, error) {
+	err := validateDigits(digits)
+	if err != nil {
+		return -1, err
+	}
+
 	Sbits, err := dynamicTruncate(secret, counter, hasher)
 	if err != nil {
-		return "", err
+		return -1, err
 	}
 
-	code := int(Sbits % int32(math.Pow10(digits)))
+	return reduce(Sbits, digits), nil
+}
+
+/*
+** can be used directly without needing to construct an Hotp object. The secret is used as its raw
+** bytes, so a string holding binary data works, but only as long as nothing on the way re-encoded it:
+** decoding it from JSON or ranging over it as runes turns invalid UTF-8 into U+FFFD and changes the
+** key. Use CalculateCodeBytes and CreateHotpBytes for binary secrets
+ */
+func CalculateCode(secret string, counter uint64, digits int, hasher func() hash.Hash) (string, error) {
+	return CalculateCodeBytes([]byte(secret), counter, digits, hasher)
+}
+
+// same as CalculateCode, for binary secrets
+func CalculateCodeBytes(secret []byte, counter uint64, digits int, hasher func() hash.Hash) (string, error) {
+	code, err := calculateInt(secret, counter, digits, hasher)
+	if err != nil {
+		return "", err
+	}
 
 	return formatCode(code, digits), nil
 }
 
+// same as CalculateCode, but takes the algorithm instead of the hash constructor
+func CalculateCodeWith(secret string, counter uint64, digits int, algorithm HashFunc) (string, error) {
+	hasher, err := hasherForCall(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	return CalculateCode(secret, counter, digits, hasher)
+}
+
 // can be used directly without needing to construct an Hotp object
 func Validate(secret string, counter uint64, digits int, code int, hasher func() hash.Hash) (bool, error) {
+	return validateString(secret, counter, digits, formatCode(code, digits), hasher)
+}
+
+// compares the code as is, so a code with the wrong number of digits never matches
+func validateString(secret string, counter uint64, digits int, code string, hasher func() hash.Hash) (bool, error) {
 	correctCode, err := CalculateCode(secret, counter, digits, hasher)
 	if err != nil {
 		return false, err
 	}
 
-	formattedCode := formatCode(code, digits)
-	fmt.Printf("%s -> %s -> %d\n", formattedCode, correctCode, counter)
-
-	return correctCode == formattedCode, nil
+	// compare in constant time so the comparison doesn't leak how much of the code matched
+	return subtle.ConstantTimeCompare([]byte(correctCode), []byte(code)) == 1, nil
 }
 
 /*
@@ -114,149 +348,1471 @@ func Validate(secret string, counter uint64, digits int, code int, hasher func()
 ** and a default look ahead window of 0
  */
 func CreateHotp(secret string, counter uint64, digits int, label string) Hotp {
+	hotp := CreateHotpBytes([]byte(secret), counter, digits)
+	hotp.label = label
+
+	return hotp
+}
+
+// same as CreateHotp, for binary secrets. The secret is copied, so the caller can reuse or wipe its slice
+func CreateHotpBytes(secret []byte, counter uint64, digits int) Hotp {
 	return Hotp{
-		secret:          secret,
-		label:           label,
+		secret:          bytes.Clone(secret),
 		counter:         counter,
 		digits:          digits,
 		lookAheadWindow: 0,
 		hashFunc:        SHA1,
 		hasher:          sha1.New,
+		mac:             &cachedMac{},
+		zeroized:        &atomic.Bool{},
+		mu:              &sync.Mutex{},
+	}
+}
+
+// same as CreateHotp, but returns an error if the secret is empty or the digits are out of range
+func CreateHotpChecked(secret string, counter uint64, digits int, label string) (Hotp, error) {
+	return createHotpChecked(secret, counter, digits, label, SHA1)
+}
+
+// the checked constructors with the algorithm set up front, so FIPS mode only sees the one that is used
+func createHotpChecked(secret string, counter uint64, digits int, label string, hashFunc HashFunc) (Hotp, error) {
+	hotp, err := createHotpValidated(secret, counter, digits, label)
+	if err != nil {
+		return Hotp{}, err
+	}
+
+	err = hotp.SetHashFunc(hashFunc)
+	if err != nil {
+		return Hotp{}, err
 	}
+
+	return hotp, nil
+}
+
+// CreateHotp with the secret and digits checked, still on the default SHA1
+func createHotpValidated(secret string, counter uint64, digits int, label string) (Hotp, error) {
+	if secret == "" {
+		return Hotp{}, ErrEmptySecret
+	}
+
+	err := validateDigits(digits)
+	if err != nil {
+		return Hotp{}, err
+	}
+
+	return CreateHotp(secret, counter, digits, label), nil
+}
+
+/*
+** creates an hotp object from a base32 encoded secret, like the ones shown by authenticator apps.
+** The secret is decoded to its raw bytes before being used as the hmac key, which is what
+** Google Authenticator and most other apps do. Passing the encoded string to CreateHotp instead
+** would hmac the base32 text itself and produce different codes
+ */
+func CreateHotpFromBase32(encodedSecret string, counter uint64, digits int, label string) (Hotp, error) {
+	return CreateHotpWithEncoding(encodedSecret, Base32, counter, digits, label)
+}
+
+// creates an hotp object from a secret in the given encoding, so the hmac key is always the decoded bytes
+func CreateHotpWithEncoding(secret string, encoding SecretEncoding, counter uint64, digits int, label string) (Hotp, error) {
+	decoded, err := DecodeSecretAs(secret, encoding)
+	if err != nil {
+		return Hotp{}, err
+	}
+
+	return CreateHotpChecked(string(decoded), counter, digits, label)
 }
 
+// sets the account name used in the otpauth uri, i.e. the account in Issuer:account
 func (hotp *Hotp) SetLabel(label string) {
 	hotp.label = label
 }
 
-func (hotp *Hotp) SetLookAheadWindow(size int) error {
+func (hotp Hotp) GetLabel() string {
+	return hotp.label
+}
+
+/*
+** by default the issuer is both the label prefix (Issuer:account) and the issuer parameter, which is what
+** the key uri format recommends for compatibility. Some older apps show the issuer twice when both are
+** present, omitting the prefix fixes that, but apps that only read the label won't show an issuer at all
+ */
+func (hotp *Hotp) SetOmitIssuerLabel(omit bool) {
+	hotp.omitIssuerLabel = omit
+}
+
+// writes the counter parameter as 0x prefixed hex, e.g. counter=0x0c, for apps that expect it. ParseOtpAuthURI reads both
+func (hotp *Hotp) SetHexCounter(hex bool) {
+	hotp.hexCounter = hex
+}
+
+// sets the issuer for this object only. An empty issuer falls back to the package issuer from the ISSUER env
+func (hotp *Hotp) SetIssuer(issuer string) {
+	hotp.issuer = issuer
+}
+
+// returns the issuer used in the otpauth uri
+func (hotp Hotp) GetIssuer() string {
+	return hotp.issuerOrDefault()
+}
+
+// a negative size would wrap around to a huge window once converted for the validation loop
+func validateWindow(size int, name string) error {
 	if size > maxLookAheadSize {
-		return fmt.Errorf("size cannot be greater than %d for look ahead window. Please set it to a smaller value", maxLookAheadSize)
+		return fmt.Errorf("%w for the %s window. Please set it to a smaller value", ErrWindowTooLarge, name)
+	}
+
+	if size < 0 {
+		return fmt.Errorf("%w for the %s window. Got: %d", ErrNegativeWindow, name, size)
+	}
+
+	return nil
+}
+
+/*
+** rfc4226 section 5.3 truncates from an offset taken from the hmac, but some hardware tokens read
+** from a fixed offset instead. offset has to be >= 0 and <= 15, or -1 to go back to dynamic truncation
+ */
+func (hotp *Hotp) SetTruncationOffset(offset int) error {
+	if offset == dynamicOffset {
+		hotp.truncationOffset = 0
+		hotp.fixedOffset = false
+		return nil
+	}
+
+	if offset < 0 || offset > maxOffset {
+		return fmt.Errorf("truncation offset has to be >= 0 and <= %d, or %d for dynamic truncation. Got: %d", maxOffset, dynamicOffset, offset)
+	}
+
+	hotp.truncationOffset = offset
+	hotp.fixedOffset = true
+	return nil
+}
+
+/*
+** rfc4226 hashes the counter as 8 big endian bytes, but some legacy hardware tokens use 4. n has to be
+** 4 or 8. With 4 a counter past 4294967295 can't be represented and is reported as ErrCounterExhausted
+ */
+func (hotp *Hotp) SetCounterBytes(n int) error {
+	if n != defaultCounterBytes && n != legacyCounterBytes {
+		return fmt.Errorf("counter bytes has to be %d or %d. Got: %d", legacyCounterBytes, defaultCounterBytes, n)
+	}
+
+	hotp.counterBytes = n
+	return nil
+}
+
+func (hotp Hotp) GetCounterBytes() int {
+	if hotp.counterBytes == 0 {
+		return defaultCounterBytes
+	}
+
+	return hotp.counterBytes
+}
+
+// returns the fixed truncation offset, or -1 when dynamic truncation is used
+func (hotp Hotp) GetTruncationOffset() int {
+	if !hotp.fixedOffset {
+		return dynamicOffset
+	}
+
+	return hotp.truncationOffset
+}
+
+func (hotp *Hotp) SetLookAheadWindow(size int) error {
+	err := validateWindow(size, "look ahead")
+	if err != nil {
+		return err
 	}
 
 	hotp.lookAheadWindow = size
 	return nil
 }
 
+func (hotp *Hotp) SetLookBehindWindow(size int) error {
+	err := validateWindow(size, "look behind")
+	if err != nil {
+		return err
+	}
+
+	hotp.lookBehindWindow = size
+	return nil
+}
+
+// sets both the look ahead and look behind window at once, whichever is bigger is used in each direction
+func (hotp *Hotp) SetResyncWindow(size int) error {
+	err := validateWindow(size, "resync")
+	if err != nil {
+		return err
+	}
+
+	hotp.resyncWindow = size
+	return nil
+}
+
+/*
+** GetCounter, Calculate and the other value receiver methods work on a copy of the object, which is
+** taken without the lock. Don't call them while another goroutine validates or moves the counter,
+** read it with Counter instead, or CalculateWithMeta for the code along with it
+ */
 func (hotp Hotp) GetCounter() uint64 {
 	return hotp.counter
 }
 
-func (hotp *Hotp) SetHashFunc(hashFunc HashFunc) error {
-	switch hashFunc {
-	case SHA1:
-		hotp.hashFunc = SHA1
-		hotp.hasher = sha1.New
-		return nil
-	case SHA256:
-		hotp.hashFunc = SHA256
-		hotp.hasher = sha256.New
-		return nil
-	case SHA512:
-		hotp.hashFunc = SHA512
-		hotp.hasher = sha512.New
-		return nil
-	default:
-		return fmt.Errorf("hashing function '%s' not implemtented", hashFunc)
-	}
+// same as GetCounter, but read under the lock, so it is safe next to concurrent Validate calls
+func (hotp *Hotp) Counter() uint64 {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	return hotp.counter
 }
 
-func (hotp *Hotp) IncrementCounter() {
-	hotp.counter += 1
+func (hotp Hotp) GetDigits() int {
+	return hotp.digits
 }
 
-func (hotp *Hotp) SetCounter(counter uint64) {
-	hotp.counter = counter
+func (hotp Hotp) GetHashFunc() HashFunc {
+	return hotp.hashFunc
+}
+
+func (hotp Hotp) GetLookAheadWindow() int {
+	return hotp.lookAheadWindow
+}
+
+func (hotp Hotp) GetLookBehindWindow() int {
+	return hotp.lookBehindWindow
+}
+
+// the secret itself isn't exposed so it can't end up in logs by accident
+func (hotp Hotp) HasSecret() bool {
+	return len(hotp.liveSecret()) > 0
 }
 
 /*
-* Validate will take a code, and check to see if it matches the output of CalculateCode
-* The lookAheadWindow field is used here to determine if the client is out of sync with the server,
-* and if necessary, alter the counter on the hotp to match. This is described in rfc4226 section 7.4
-* Upon success, increments the counter
+** returns an independent copy of the hotp object, with its own lock. Changing the counter or configuration
+** of the clone doesn't affect the original, which makes it useful for dry run validation or as a template
  */
-func (hotp *Hotp) Validate(code int) (bool, error) {
-	validated, err := Validate(hotp.secret, hotp.counter, hotp.digits, code, hotp.hasher)
-	if err != nil {
-		return false, err
-	}
+func (hotp Hotp) Clone() Hotp {
+	clone := hotp
+	clone.secret = bytes.Clone(hotp.secret)
+	clone.mac = &cachedMac{}
+	clone.zeroized = &atomic.Bool{}
+	clone.zeroized.Store(hotp.isZeroized())
+	clone.mu = &sync.Mutex{}
+
+	return clone
+}
+
+// describes the configuration with the secret redacted, so printing the object can't leak it
+func (hotp Hotp) String() string {
+	return fmt.Sprintf("Hotp{counter=%d digits=%d algorithm=%s lookAheadWindow=%d lookBehindWindow=%d label=%q secret=<redacted>}",
+		hotp.counter,
+		hotp.digits,
+		hotp.hashFunc,
+		hotp.lookAheadWindow,
+		hotp.lookBehindWindow,
+		hotp.label,
+	)
+}
+
+// used for %#v, which would otherwise print the secret field
+func (hotp Hotp) GoString() string {
+	return hotp.String()
+}
+
+/*
+** reports whether both objects have the same configuration: secret, counter, digits, algorithm,
+** windows, label, issuer, truncation offset and max failures. The hasher, encoder and hooks are
+** functions and can't be compared, the algorithm stands in for the hasher. Validation state like
+** the failures and the last validated counter is ignored
+ */
+func (hotp Hotp) Equal(other Hotp) bool {
+	return subtle.ConstantTimeCompare(hotp.secret, other.secret) == 1 &&
+		hotp.counter == other.counter &&
+		hotp.digits == other.digits &&
+		hotp.hashFunc == other.hashFunc &&
+		hotp.lookAheadWindow == other.lookAheadWindow &&
+		hotp.lookBehindWindow == other.lookBehindWindow &&
+		hotp.resyncWindow == other.resyncWindow &&
+		hotp.label == other.label &&
+		hotp.issuer == other.issuer &&
+		hotp.omitIssuerLabel == other.omitIssuerLabel &&
+		hotp.hexCounter == other.hexCounter &&
+		hotp.GetTruncationOffset() == other.GetTruncationOffset() &&
+		hotp.GetCounterBytes() == other.GetCounterBytes() &&
+		hotp.maxFailures == other.maxFailures
+}
 
-	if validated {
-		hotp.IncrementCounter()
-		return true, nil
+// maps a HashFunc to the hash constructor used for the hmac
+func hasherFor(hashFunc HashFunc) (func() hash.Hash, error) {
+	switch hashFunc {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
 	}
 
-	if !validated && (hotp.lookAheadWindow == 0) {
-		return false, nil
+	hashRegistryMu.RLock()
+	defer hashRegistryMu.RUnlock()
+
+	hasher, ok := hashRegistry[hashFunc]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedHash, hashFunc)
 	}
 
-	for i := range uint64(hotp.lookAheadWindow) {
-		// make i one based to adjust the counter upon success
-		i += 1
+	return hasher, nil
+}
 
-		newCounter := hotp.counter + i
-		validated, err := Validate(hotp.secret, newCounter, hotp.digits, code, hotp.hasher)
-		if err != nil {
-			return false, err
-		}
+// maps any spelling of an algorithm to its constant, "SHA1", "sha1" and "SHA-1" are all SHA1. Unknown algorithms are an error
+func ParseHashFunc(name string) (HashFunc, error) {
+	hashFunc := HashFunc(strings.ReplaceAll(strings.ToLower(name), "-", ""))
 
-		if validated {
-			// resynchronize the counter on the object to get it back with the client
-			hotp.counter += i
-			return true, nil
+	_, err := hasherFor(hashFunc)
+	if err != nil {
+		// registered names are matched as is as well, in case they aren't lowercase
+		_, registeredErr := hasherFor(HashFunc(name))
+		if registeredErr != nil {
+			return "", err
 		}
+
+		return HashFunc(name), nil
 	}
 
-	return false, nil
+	return hashFunc, nil
 }
 
-func (hotp Hotp) Calculate() (string, error) {
-	return CalculateCode(hotp.secret, hotp.counter, hotp.digits, hotp.hasher)
+func (hashFunc HashFunc) MarshalText() ([]byte, error) {
+	return []byte(hashFunc), nil
 }
 
-func (hotp Hotp) GenerateOtpAuth() string {
-	params := hotp.GenerateOtpAuthParams()
+// accepts any casing of a known algorithm, i.e. "SHA256" becomes SHA256. Unknown algorithms are an error
+func (hashFunc *HashFunc) UnmarshalText(text []byte) error {
+	normalized, err := ParseHashFunc(string(text))
+	if err != nil {
+		return err
+	}
 
-	return fmt.Sprintf("otpauth://hotp/%s", params)
+	*hashFunc = normalized
+	return nil
 }
 
-// generates a random []byte of length. Note 10-20 is generally secure for hotp
-func GenerateSecret(length int) []byte {
-	secret := make([]byte, length)
+/*
+** registers an extra hashing function for tokens that don't use SHA-1, SHA-256 or SHA-512,
+** i.e. RegisterHashFunc("sha224", sha256.New224). Once registered, the name can be passed to SetHashFunc.
+** The built in algorithms can't be overridden
+ */
+func RegisterHashFunc(name HashFunc, factory func() hash.Hash) error {
+	if factory == nil {
+		return fmt.Errorf("hashing function '%s' needs a non nil factory", name)
+	}
 
-	// rand.Read will never return an error
-	_, _ = rand.Read(secret)
+	switch name {
+	case SHA1, SHA256, SHA512:
+		return fmt.Errorf("hashing function '%s' is built in and can't be registered", name)
+	}
 
-	return secret
-}
+	hashRegistryMu.Lock()
+	defer hashRegistryMu.Unlock()
 
-// returns a string that is base32 encoded
-func EncodeSecret(secret []byte) string {
-	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
-	return encoded
+	hashRegistry[name] = factory
+	return nil
 }
 
-// returns a string that is base32 decoded
-func DecodeSecret(secret string) (string, error) {
-	decoded, err := base32.StdEncoding.DecodeString(secret)
-	if err != nil {
-		return "", err
+/*
+** returns the algorithms SetHashFunc accepts, the built in ones first and then any added with
+** RegisterHashFunc in alphabetical order. SHA1 is left out while FIPS mode is on
+ */
+func SupportedHashFuncs() []HashFunc {
+	supported := []HashFunc{SHA256, SHA512}
+	if !IsFIPSMode() {
+		supported = []HashFunc{SHA1, SHA256, SHA512}
 	}
 
-	return string(decoded), nil
-}
+	hashRegistryMu.RLock()
+	registered := make([]HashFunc, 0, len(hashRegistry))
+	for name := range hashRegistry {
+		registered = append(registered, name)
+	}
+	hashRegistryMu.RUnlock()
+
+	slices.Sort(registered)
+
+	return append(supported, registered...)
+}
+
+// accepts any spelling ParseHashFunc does, GetHashFunc returns the canonical constant
+func (hotp *Hotp) SetHashFunc(hashFunc HashFunc) error {
+	hashFunc, err := ParseHashFunc(string(hashFunc))
+	if err != nil {
+		return err
+	}
+
+	err = checkFIPS(hashFunc)
+	if err != nil {
+		return err
+	}
+
+	return hotp.restoreHashFunc(hashFunc)
+}
+
+// same as SetHashFunc without the FIPS check, for tokens that already exist, see RestoreHotp
+func (hotp *Hotp) restoreHashFunc(hashFunc HashFunc) error {
+	hashFunc, err := ParseHashFunc(string(hashFunc))
+	if err != nil {
+		return err
+	}
+
+	hasher, err := hasherFor(hashFunc)
+	if err != nil {
+		return err
+	}
+
+	hotp.hashFunc = hashFunc
+	hotp.hasher = hasher
+	hotp.customHasher = false
+	return nil
+}
+
+// NOTE: wraps around to 0 at math.MaxUint64, see IncrementCounterChecked
+func (hotp *Hotp) IncrementCounter() {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.counter += 1
+}
+
+// same as IncrementCounter, but returns an error instead of wrapping around to 0, which would reuse every code
+func (hotp *Hotp) IncrementCounterChecked() error {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	if hotp.counter == math.MaxUint64 {
+		return ErrCounterExhausted
+	}
+
+	hotp.counter += 1
+	return nil
+}
+
+/*
+** returns the code for the current counter and moves the counter past it in one step, for clients
+** displaying sequential codes. The client side counterpart of Validate
+ */
+func (hotp *Hotp) Next() (string, error) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	if hotp.counter == math.MaxUint64 {
+		return "", ErrCounterExhausted
+	}
+
+	code, err := hotp.codeWith(hotp.counter, hotp.keyedMac())
+	if err != nil {
+		return "", err
+	}
+
+	hotp.counter += 1
+	return code, nil
+}
+
+// sets the counter, dropping a pending two step resync as it was relative to the old one
+func (hotp *Hotp) SetCounter(counter uint64) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.counter = counter
+	hotp.hasPendingResync = false
+}
+
+/*
+** sets the counter to unixtime / interval, for legacy schemes that run hotp with a time derived counter.
+** Unlike Totp, the counter isn't kept in sync with the clock, it is only set when this is called.
+** A clock before the unix epoch is an error, as it is for WithCounterFromTime
+ */
+func (hotp *Hotp) SyncCounterToTime(interval int) error {
+	if interval < 1 {
+		return fmt.Errorf("%w. Got: %d", ErrInvalidTimeStep, interval)
+	}
+
+	now := timeNow()
+	if now.Unix() < 0 {
+		return fmt.Errorf("the counter can't start before the unix epoch. Got: %s", now)
+	}
+
+	hotp.SetCounter(uint64(now.Unix() / int64(interval)))
+	return nil
+}
+
+// sets the counter back to 0, e.g. after re-enrolling a device
+func (hotp *Hotp) Reset() {
+	hotp.SetCounter(0)
+}
+
+/*
+** overwrites the secret with zeros once the token is no longer needed, to shorten the time it sits in
+** memory, and marks the object unusable for good: Calculate, Validate and the rest return ErrZeroized.
+** Plain copies of the object share the secret buffer, so they are zeroized along with it. Clones have
+** their own secret and aren't touched, neither are strings the secret was created from.
+** The cached hmac (see keyedMac) holds the key xor'd into its pads, which crypto/hmac gives no way to
+** wipe. Zeroize drops it for the object and its plain copies, but it stays in memory until the
+** garbage collector reuses it
+ */
+func (hotp *Hotp) Zeroize() {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	clear(hotp.secret)
+	hotp.secret = nil
+	if hotp.mac != nil {
+		*hotp.mac = cachedMac{}
+	}
+	if hotp.zeroized == nil {
+		hotp.zeroized = &atomic.Bool{}
+	}
+	hotp.zeroized.Store(true)
+}
+
+func (hotp Hotp) isZeroized() bool {
+	return hotp.zeroized != nil && hotp.zeroized.Load()
+}
+
+// the secret, or nil once Zeroize wiped it, also for copies that still point at the wiped buffer
+func (hotp Hotp) liveSecret() []byte {
+	if hotp.isZeroized() {
+		return nil
+	}
+
+	return hotp.secret
+}
+
+/*
+** replaces the secret and resets the counter to 0 in one step, so no code is ever checked against a mix of the two.
+** The counters consumed and the pending resync belonged to the old secret, so they are forgotten as well
+ */
+func (hotp *Hotp) RotateSecret(secret string) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.secret = []byte(secret)
+	hotp.counter = 0
+	hotp.lastValidatedCounter = 0
+	hotp.hasValidated = false
+	hotp.pendingResync = 0
+	hotp.hasPendingResync = false
+}
+
+/*
+* Validate will take a code, and check to see if it matches the output of CalculateCode
+* The lookAheadWindow field is used here to determine if the client is out of sync with the server,
+* and if necessary, alter the counter on the hotp to match. This is described in rfc4226 section 7.4
+* The lookBehindWindow field does the same for counters below the current one, for when the server
+* has advanced past the client, and the resyncWindow field covers both directions at once.
+* The exact counter is tried first, then the nearest counters, ahead before behind
+* Upon success, moves the counter to the one after the matched counter. Safe to call concurrently, and
+* the look behind and resync windows skip counters that were already consumed, so a code is only ever
+* accepted once. Moving the counter back with SetCounter or turning off auto advance reopens old codes,
+* VerifyAndAdvance refuses those as well
+ */
+func (hotp *Hotp) Validate(code int) (bool, error) {
+	return hotp.ValidateContext(context.Background(), code)
+}
+
+// same as Validate, but gives up with the context's error once ctx is done, checked before every counter in the windows
+func (hotp *Hotp) ValidateContext(ctx context.Context, code int) (bool, error) {
+	validated, _, err := hotp.validate(ctx, formatCode(code, hotp.digits))
+	return validated, err
+}
+
+/*
+* same as Validate, but takes the code the way the user typed it, e.g. "0338314". The string is compared
+* as is, so leading zeros count and a code with a different number of digits than the object never matches
+ */
+func (hotp *Hotp) ValidateString(code string) (bool, error) {
+	validated, _, err := hotp.validate(context.Background(), code)
+	return validated, err
+}
+
+/*
+* same as ValidateString, but for decimal codes typed by a user: surrounding whitespace is trimmed, and
+* anything that isn't exactly digits decimal digits is an ErrInvalidCode instead of a mismatch.
+* Rejected input doesn't count as a failure and doesn't fire the hooks
+ */
+func (hotp *Hotp) ValidateCode(code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) != hotp.digits {
+		return false, fmt.Errorf("%w: expected %d digits. Got: %d", ErrInvalidCode, hotp.digits, len(code))
+	}
+
+	for i, r := range code {
+		if r < '0' || r > '9' {
+			return false, fmt.Errorf("%w: '%c' at position %d is not a digit", ErrInvalidCode, r, i)
+		}
+	}
+
+	return hotp.ValidateString(code)
+}
+
+/*
+* ValidateWithDelta is the same as Validate, but also returns how many counters ahead of the
+* current one the code matched at. 0 is an exact match, anything else means the client drifted.
+* A code matched behind the counter also reports 0, as the delta can't be negative. Use
+* ValidateWithResync to know the direction. The drift tracker, if one is set, gets the signed delta
+ */
+func (hotp *Hotp) ValidateWithDelta(code int) (bool, uint64, error) {
+	validated, delta, err := hotp.validate(context.Background(), formatCode(code, hotp.digits))
+	if validated && hotp.driftTracker != nil {
+		hotp.driftTracker.Record(int(delta))
+	}
+
+	if delta < 0 {
+		return validated, 0, err
+	}
+
+	return validated, uint64(delta), err
+}
+
+/*
+* ValidateWithResync is the same as Validate, but returns the signed distance between the counter
+* the code matched at and the current one. Positive means the client is ahead, negative behind
+ */
+func (hotp *Hotp) ValidateWithResync(code int) (bool, int64, error) {
+	return hotp.validate(context.Background(), formatCode(code, hotp.digits))
+}
+
+/*
+* VerifyAndAdvance validates the code the same way Validate does, but makes the replay guarantee
+* explicit: a match at or below GetLastValidatedCounter is rejected, whatever the counter and the
+* windows are set to, so submitting the same code again will never validate. Not even after the
+* counter was moved back with SetCounter, or with auto advance off. GetLastValidatedCounter returns
+* the highest counter consumed, for callers persisting state that want to reject stale retries
+ */
+func (hotp *Hotp) VerifyAndAdvance(code int) (bool, error) {
+	validated, _, err := hotp.validateWith(func() (bool, int64, error) {
+		validated, delta, err := hotp.search(context.Background(), formatCode(code, hotp.digits))
+		if err != nil || !validated {
+			return validated, delta, err
+		}
+
+		if hotp.consumed(offsetCounter(hotp.counter, delta)) {
+			return false, 0, nil
+		}
+
+		return true, delta, nil
+	})
+
+	return validated, err
+}
+
+/*
+** checks the code at exactly counter, ignoring the windows, and leaves the object as is: the counter,
+** the failures and the hooks are untouched. For callers keeping the counter elsewhere, e.g. a database
+** row updated with optimistic locking, that decide themselves when to advance it
+ */
+func (hotp *Hotp) ValidateAt(code int, counter uint64) (bool, error) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	return hotp.matches(counter, formatCode(code, hotp.digits))
+}
+
+// returns the highest counter a code was accepted at, and false if no code has been accepted yet
+func (hotp *Hotp) GetLastValidatedCounter() (uint64, bool) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	return hotp.lastValidatedCounter, hotp.hasValidated
+}
+
+// reports whether a code was already accepted at counter or a later one. Must be called with mu held
+func (hotp *Hotp) consumed(counter uint64) bool {
+	return hotp.hasValidated && counter <= hotp.lastValidatedCounter
+}
+
+// consumes the matched counter, the next code expected is the one after it. Must be called with mu held
+func (hotp *Hotp) accept(counter uint64) {
+	if !hotp.consumed(counter) {
+		hotp.lastValidatedCounter = counter
+	}
+	hotp.hasValidated = true
+	if !hotp.manualCounter {
+		hotp.counter = counter + 1
+	}
+	hotp.failures = 0
+	hotp.hasPendingResync = false
+}
+
+/*
+** locks out validation after n consecutive rejected codes, to slow down online brute forcing.
+** Once locked out every validate method returns ErrLockedOut, even for a correct code, until
+** ResetFailures is called. An accepted code resets the count. 0 turns the limit off
+ */
+func (hotp *Hotp) SetMaxFailures(n int) error {
+	if n < 0 {
+		return fmt.Errorf("max failures has to be >= 0. Got: %d", n)
+	}
+
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.maxFailures = n
+	return nil
+}
+
+// clears the consecutive failures, lifting a lockout
+func (hotp *Hotp) ResetFailures() {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.failures = 0
+}
+
+// returns how many codes in a row have been rejected
+func (hotp *Hotp) GetFailures() int {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	return hotp.failures
+}
+
+func (hotp *Hotp) validate(ctx context.Context, code string) (bool, int64, error) {
+	return hotp.validateWith(func() (bool, int64, error) {
+		return hotp.search(ctx, code)
+	})
+}
+
+// runs find with mu held and accepts what it matched. find returns the delta from the current counter
+func (hotp *Hotp) validateWith(find func() (bool, int64, error)) (bool, int64, error) {
+	validated, delta, err := hotp.validateLocked(find)
+	if err == nil {
+		hotp.fireHooks(validated, delta)
+	}
+
+	return validated, delta, err
+}
+
+func (hotp *Hotp) validateLocked(find func() (bool, int64, error)) (bool, int64, error) {
+	// held for the whole check so concurrent calls can't accept the same counter twice
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	err := hotp.checkUsable()
+	if err != nil {
+		return false, 0, err
+	}
+
+	validated, delta, err := find()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if !validated {
+		hotp.reject()
+		return false, 0, nil
+	}
+
+	_, err = hotp.commit(delta)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return true, delta, nil
+}
+
+// accepts a match at delta from the counter, unless two step resync holds it back. Must be called with mu held
+func (hotp *Hotp) commit(delta int64) (uint64, error) {
+	matched := offsetCounter(hotp.counter, delta)
+
+	// the second code of a resync has to be the one right after the first
+	if hotp.twoStepResync && delta > 0 && (!hotp.hasPendingResync || matched != hotp.pendingResync+1) {
+		hotp.pendingResync = matched
+		hotp.hasPendingResync = true
+		return 0, ErrResyncPending
+	}
+
+	hotp.accept(matched)
+	return matched, nil
+}
+
+// counts a rejected code. Must be called with mu held
+func (hotp *Hotp) reject() {
+	hotp.failures++
+	hotp.hasPendingResync = false
+}
+
+/*
+** on by default, an accepted code moves the counter past the one it matched. Turned off the validate
+** methods leave the counter as is, for callers that persist it themselves: advance the store by the
+** returned delta, or to GetLastValidatedCounter + 1, then SetCounter. Until then the same code keeps
+** validating, so the caller is responsible for rejecting replays
+ */
+func (hotp *Hotp) SetAutoAdvance(enabled bool) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.manualCounter = !enabled
+}
+
+/*
+** with two step resync on, a code matched ahead of the counter doesn't move it on its own. Validate
+** returns ErrResyncPending instead, and the jump is only committed when the next code submitted is
+** the one right after it, as rfc4226 section 7.4 suggests. Otherwise a single old or guessed code
+** far ahead could push the counter past the legitimate client. Exact and look behind matches are
+** accepted as before
+ */
+func (hotp *Hotp) EnableTwoStepResync(enabled bool) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	hotp.twoStepResync = enabled
+	hotp.hasPendingResync = false
+}
+
+/*
+** same as Validate, but window is the look ahead window for this call only, instead of the windows set
+** on the object. The look behind and resync windows aren't used. The counter still advances on success
+ */
+func (hotp *Hotp) ValidateWithWindow(code int, window int) (bool, error) {
+	err := validateWindow(window, "look ahead")
+	if err != nil {
+		return false, err
+	}
+
+	validated, _, err := hotp.validateWith(func() (bool, int64, error) {
+		// a copy, so the windows of the object itself never change. mu is held by validateLocked
+		windowed := *hotp
+		windowed.lookAheadWindow = window
+		windowed.lookBehindWindow = 0
+		windowed.resyncWindow = 0
+
+		return windowed.search(context.Background(), formatCode(code, hotp.digits))
+	})
+
+	return validated, err
+}
+
+/*
+** same as ValidateString, but when the code has a different number of digits than the object it is
+** also tried at its own length, e.g. "755224" for an object set up with 8 digits. This smooths over
+** tokens enrolled with the wrong digits. NOTE: every digit less is 10 times easier to guess, so a
+** 6 digit code accepted by an 8 digit object is only as strong as a 6 digit code. Only shorter codes
+** of at least the 6 digits rfc4226 requires (R-4) are retried, so a 1 digit guess never matches.
+** Only decimal codes are retried, an object with an encoder validates the same as ValidateString
+ */
+func (hotp *Hotp) ValidateFlexibleDigits(code string) (bool, error) {
+	validated, _, err := hotp.validateWith(func() (bool, int64, error) {
+		validated, delta, err := hotp.search(context.Background(), code)
+		if err != nil || validated || hotp.encoder != nil || len(code) < minFlexibleDigits || len(code) >= hotp.digits {
+			return validated, delta, err
+		}
+
+		// a copy, so the digits of the object itself never change. mu is held by validateLocked
+		observed := *hotp
+		observed.digits = len(code)
+
+		return observed.search(context.Background(), code)
+	})
+
+	return validated, err
+}
+
+/*
+** same as Validate, but the code is tried with every algorithm in algos in order, e.g. during a SHA1 to
+** SHA256 migration where clients move over one by one. Returns the algorithm that matched, the object
+** keeps its own, so call SetHashFunc once a client is known to have moved. The counter advances once
+ */
+func (hotp *Hotp) ValidateMultiAlgo(code int, algos []HashFunc) (bool, HashFunc, error) {
+	if len(algos) == 0 {
+		return false, "", fmt.Errorf("%w: no algorithms to validate with", ErrUnsupportedHash)
+	}
+
+	names := make([]HashFunc, len(algos))
+	hashers := make([]func() hash.Hash, len(algos))
+	for i, algorithm := range algos {
+		algorithm, err := ParseHashFunc(string(algorithm))
+		if err != nil {
+			return false, "", err
+		}
+
+		err = checkFIPS(algorithm)
+		if err != nil {
+			return false, "", err
+		}
+
+		hashers[i], err = hasherFor(algorithm)
+		if err != nil {
+			return false, "", err
+		}
+
+		names[i] = algorithm
+	}
+
+	var matched HashFunc
+	validated, _, err := hotp.validateWith(func() (bool, int64, error) {
+		for i, hasher := range hashers {
+			// a copy with its own mac, so the object keeps its hasher. mu is held by validateLocked
+			candidate := *hotp
+			candidate.hasher = hasher
+			candidate.mac = &cachedMac{}
+
+			validated, delta, err := candidate.search(context.Background(), formatCode(code, hotp.digits))
+			if err != nil || validated {
+				matched = names[i]
+				return validated, delta, err
+			}
+		}
+
+		return false, 0, nil
+	})
+
+	if !validated {
+		return false, "", err
+	}
+
+	return true, matched, nil
+}
+
+// the checks before any code is compared. Must be called with mu held
+func (hotp *Hotp) checkUsable() error {
+	// accepting a code at the last counter would wrap the counter back to 0 and reuse every code
+	if hotp.counter == math.MaxUint64 {
+		return ErrCounterExhausted
+	}
+
+	if hotp.maxFailures > 0 && hotp.failures >= hotp.maxFailures {
+		return ErrLockedOut
+	}
+
+	return nil
+}
+
+/*
+** same as Validate, but tries each of the candidate codes in order and stops at the first one that
+** matches within the windows. The counter only advances once, past the counter that code matched at
+ */
+func (hotp *Hotp) ValidateAny(codes []int) (bool, uint64, error) {
+	validated, matched, delta, err := hotp.validateAnyLocked(codes)
+	if err == nil {
+		hotp.fireHooks(validated, delta)
+	}
+
+	return validated, matched, err
+}
+
+func (hotp *Hotp) validateAnyLocked(codes []int) (bool, uint64, int64, error) {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	err := hotp.checkUsable()
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	for _, code := range codes {
+		validated, delta, err := hotp.search(context.Background(), formatCode(code, hotp.digits))
+		if err != nil {
+			return false, 0, 0, err
+		}
+
+		if validated {
+			matched, err := hotp.commit(delta)
+			if err != nil {
+				return false, 0, 0, err
+			}
+
+			return true, matched, delta, nil
+		}
+	}
+
+	// one submission, so one failure however many candidates it had
+	hotp.reject()
+	return false, 0, 0, nil
+}
+
+/*
+** sets a callback for every accepted code, e.g. to feed a metric of how far clients drift.
+** delta is how many counters away from the expected one the code matched, in either direction.
+** Called after the lock is released, so it can use the object
+ */
+func (hotp *Hotp) OnValidateSuccess(hook func(delta uint64)) {
+	hotp.onSuccess = hook
+}
+
+// sets a callback for every rejected code. Validation errors, e.g. a cancelled context, don't count
+func (hotp *Hotp) OnValidateFailure(hook func()) {
+	hotp.onFailure = hook
+}
+
+func (hotp *Hotp) fireHooks(validated bool, delta int64) {
+	if validated && hotp.onSuccess != nil {
+		if delta < 0 {
+			delta = -delta
+		}
+
+		hotp.onSuccess(uint64(delta))
+	}
+
+	if !validated && hotp.onFailure != nil {
+		hotp.onFailure()
+	}
+}
+
+// the counter a delta from search points at
+func offsetCounter(counter uint64, delta int64) uint64 {
+	if delta < 0 {
+		return counter - uint64(-delta)
+	}
+
+	return counter + uint64(delta)
+}
+
+// looks for code at the counter and then the windows around it, nearest first. Must be called with mu held
+func (hotp *Hotp) search(ctx context.Context, code string) (bool, int64, error) {
+	validated, err := hotp.matches(hotp.counter, code)
+	if err != nil || validated {
+		return validated, 0, err
+	}
+
+	// the second code of a two step resync can be just outside of the window. Not once the counter has
+	// moved past it, e.g. with IncrementCounter, the delta would be negative and move the counter back
+	if hotp.twoStepResync && hotp.hasPendingResync && hotp.pendingResync < math.MaxUint64-1 && hotp.pendingResync+1 > hotp.counter {
+		validated, err := hotp.matches(hotp.pendingResync+1, code)
+		if err != nil {
+			return false, 0, err
+		}
+
+		if validated {
+			return true, int64(hotp.pendingResync + 1 - hotp.counter), nil
+		}
+	}
+
+	// the resync window widens both directions
+	lookAhead := max(hotp.lookAheadWindow, hotp.resyncWindow)
+	lookBehind := max(hotp.lookBehindWindow, hotp.resyncWindow)
+
+	window := max(lookAhead, lookBehind)
+	if window == 0 {
+		return false, 0, nil
+	}
+
+	for i := range uint64(window) {
+		// make i one based, it is the distance from the counter
+		i += 1
+
+		err := ctx.Err()
+		if err != nil {
+			return false, 0, err
+		}
+
+		// the counter after the match has to fit as well
+		if i <= uint64(lookAhead) && i < math.MaxUint64-hotp.counter {
+			validated, err := hotp.matches(hotp.counter+i, code)
+			if err != nil {
+				return false, 0, err
+			}
+
+			if validated {
+				return true, int64(i), nil
+			}
+		}
+
+		// the counter can't go below 0, so there is nothing further behind to check. A counter that was
+		// already consumed is skipped, otherwise the code just accepted would match again one step behind
+		if i <= uint64(lookBehind) && i <= hotp.counter && !hotp.consumed(hotp.counter-i) {
+			validated, err := hotp.matches(hotp.counter-i, code)
+			if err != nil {
+				return false, 0, err
+			}
+
+			if validated {
+				return true, -int64(i), nil
+			}
+		}
+	}
+
+	return false, 0, nil
+}
+
+func (hotp Hotp) Calculate() (string, error) {
+	return hotp.codeAt(hotp.counter)
+}
+
+/*
+** the current code along with the counter and algorithm it was calculated with, read together under the
+** lock, so a concurrent Validate can't move the counter between them like separate getters could
+ */
+func (hotp *Hotp) CalculateWithMeta() (string, uint64, HashFunc, error) {
+	hotp.mutex().Lock()
+	snapshot := *hotp
+	hotp.mu.Unlock()
+
+	code, err := snapshot.codeAt(snapshot.counter)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	return code, snapshot.counter, snapshot.hashFunc, nil
+}
+
+/*
+** same as Calculate, but with the given algorithm instead of the one configured, e.g. to check a code
+** against both while migrating from SHA1 to SHA256. The object itself is left as is
+ */
+func (hotp Hotp) CalculateUsing(algorithm HashFunc) (string, error) {
+	algorithm, err := ParseHashFunc(string(algorithm))
+	if err != nil {
+		return "", err
+	}
+
+	hasher, err := hasherForCall(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	// hotp is a copy, so this only changes the hasher for this call
+	hotp.hasher = hasher
+
+	return hotp.codeAt(hotp.counter)
+}
+
+// returns the code as a number. Leading zeros are lost, so the digits are needed to display it, e.g. 338314 is "0338314" with 7 digits
+func (hotp Hotp) CalculateInt() (int, error) {
+	err := validateDigits(hotp.digits)
+	if err != nil {
+		return -1, err
+	}
+
+	Sbits, err := hotp.sbits(hotp.counter, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	return reduce(Sbits, hotp.digits), nil
+}
+
+// calculates a Steam Guard style code. The digits field isn't used, Steam codes are always 5 characters
+func (hotp Hotp) CalculateSteam() (string, error) {
+	Sbits, err := hotp.sbits(hotp.counter, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return SteamEncoder{}.Encode(Sbits), nil
+}
+
+// returns the codes for the next count counters, starting at the current one. The counter is left as is
+func (hotp Hotp) CalculateRange(count int) ([]string, error) {
+	if count < 1 || count > maxRangeSize {
+		return nil, fmt.Errorf("count has to be >= 1 and <= %d. Got: %d", maxRangeSize, count)
+	}
+
+	if hotp.counter > math.MaxUint64-uint64(count-1) {
+		return nil, fmt.Errorf("%w: a range of %d would overflow the counter", ErrCounterExhausted, count)
+	}
+
+	codes := make([]string, 0, count)
+	for i := range uint64(count) {
+		code, err := hotp.codeAt(hotp.counter + i)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func (hotp Hotp) GenerateOtpAuth() string {
+	params := hotp.GenerateOtpAuthParams()
+
+	return fmt.Sprintf("otpauth://hotp/%s", params)
+}
+
+/*
+** same as GenerateOtpAuth, but pad controls whether the secret keeps its base32 "=" padding.
+** Most apps expect it without, which is what GenerateOtpAuth does, but a few strict ones want it
+ */
+func (hotp Hotp) GenerateOtpAuthWithPadding(pad bool) string {
+	return fmt.Sprintf("otpauth://hotp/%s", hotp.otpAuthParams(pad))
+}
+
+// generates a random []byte of length. Note 10-20 is generally secure for hotp.
+// Prefer GenerateSecretChecked, which enforces a minimum length and reports errors from the random source
+func GenerateSecret(length int) []byte {
+	// crypto/rand only fails when the OS can't provide randomness, GenerateSecretChecked surfaces that
+	secret, _ := GenerateSecretFrom(rand.Reader, length)
+
+	return secret
+}
+
+/*
+** generates a random []byte of length, rejecting anything shorter than the 128 bits rfc4226 requires.
+** DefaultSecretLength (160 bits) is the length rfc4226 recommends
+ */
+func GenerateSecretChecked(length int) ([]byte, error) {
+	if length < minSecretLength {
+		return nil, fmt.Errorf("%w. Got: %d", ErrSecretTooShort, length)
+	}
+
+	// a broken random source can hand out a buffer of a single repeated byte, e.g. all zeros.
+	// crypto/rand won't in practice, but the secret is regenerated a few times rather than trusted
+	for range maxSecretAttempts {
+		secret, err := GenerateSecretFrom(randReader, length)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isWeakSecret(secret) {
+			return secret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w after %d attempts", ErrWeakSecret, maxSecretAttempts)
+}
+
+// a secret of one repeated byte has no entropy at all
+func isWeakSecret(secret []byte) bool {
+	for _, b := range secret {
+		if b != secret[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+** reads a secret of length bytes from r instead of crypto/rand, e.g. an HSM backed reader, or a fixed
+** one in tests. A reader that ends early is an error. No minimum length is enforced here.
+** r is read without any locking, so a reader shared between goroutines has to be safe for concurrent
+** use itself. crypto/rand is, most others aren't. Wrap them in a SecretGenerator instead
+ */
+func GenerateSecretFrom(r io.Reader, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("secret length cannot be negative. Got: %d", length)
+	}
+
+	secret := make([]byte, length)
+
+	_, err := io.ReadFull(r, secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// serializes reads from a reader that isn't safe for concurrent use, so it can be shared between goroutines
+type SecretGenerator struct {
+	mu     sync.Mutex
+	reader io.Reader
+}
+
+func NewSecretGenerator(r io.Reader) *SecretGenerator {
+	return &SecretGenerator{reader: r}
+}
+
+// same as GenerateSecretFrom. Each secret is read in one go, so concurrent calls never interleave bytes
+func (generator *SecretGenerator) Generate(length int) ([]byte, error) {
+	generator.mu.Lock()
+	defer generator.mu.Unlock()
+
+	return GenerateSecretFrom(generator.reader, length)
+}
+
+/*
+** the secret length in bytes rfc6238 uses for the algorithm, the size of its output: 20 for SHA1,
+** 32 for SHA256 and 64 for SHA512. Registered algorithms use their output size as well, but never
+** less than the 16 bytes GenerateSecretChecked requires. Unknown algorithms get DefaultSecretLength
+ */
+func RecommendedSecretLength(algorithm HashFunc) int {
+	algorithm, err := ParseHashFunc(string(algorithm))
+	if err != nil {
+		return DefaultSecretLength
+	}
+
+	hasher, err := hasherFor(algorithm)
+	if err != nil {
+		return DefaultSecretLength
+	}
+
+	return max(hasher().Size(), minSecretLength)
+}
+
+// generates a secret of the recommended length for the algorithm, see RecommendedSecretLength
+func GenerateSecretFor(algorithm HashFunc) ([]byte, error) {
+	return GenerateSecretChecked(RecommendedSecretLength(algorithm))
+}
+
+// generates a secret with GenerateSecretChecked and base32 encodes it, ready for an otpauth uri or manual entry
+func GenerateSecretBase32(length int) (string, error) {
+	secret, err := GenerateSecretChecked(length)
+	if err != nil {
+		return "", err
+	}
+
+	return EncodeSecret(secret), nil
+}
+
+// checks a raw secret is long enough for rfc4226, which requires at least 128 bits
+func ValidateSecretBytes(secret []byte) error {
+	if len(secret) == 0 {
+		return ErrEmptySecret
+	}
+
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("%w. Got: %d", ErrSecretTooShort, len(secret))
+	}
+
+	return nil
+}
+
+// returns a string that is base32 encoded
+func EncodeSecret(secret []byte) string {
+	encoded := base32Encoding.EncodeToString(secret)
+	return encoded
+}
+
+// returns the base32 decoded secret as a string. See DecodeSecretBytes for binary secrets
+func DecodeSecret(secret string) (string, error) {
+	decoded, err := DecodeSecretBytes(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decoded), nil
+}
+
+// returns the raw bytes of a base32 encoded secret. Both padded and unpadded secrets are accepted
+func DecodeSecretBytes(secret string) ([]byte, error) {
+	decoded, err := base32Encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+/*
+** same as DecodeSecretBytes, but rejects what it would let through: non zero trailing bits and
+** padding that is missing characters. Unpadded secrets are still accepted. For secrets from
+** untrusted sources, where an anomaly usually means the secret was corrupted or cut short
+ */
+func DecodeSecretStrict(secret string) ([]byte, error) {
+	encoding := base32Encoding
+	if strings.Contains(secret, "=") {
+		encoding = base32.StdEncoding
+	}
+
+	decoded, err := encoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSecret, err)
+	}
+
+	// encoding/base32 has no strict mode like encoding/base64, so only the canonical encoding is accepted
+	if encoding.EncodeToString(decoded) != secret {
+		return nil, fmt.Errorf("%w: not canonical base32, it has trailing bits or padding that is off", ErrInvalidSecret)
+	}
+
+	return decoded, nil
+}
+
+// returns the raw bytes of a hex encoded secret
+func SecretFromHex(secret string) ([]byte, error) {
+	decoded, err := hex.DecodeString(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// returns the raw bytes of a secret in the given encoding
+func DecodeSecretAs(secret string, encoding SecretEncoding) ([]byte, error) {
+	switch encoding {
+	case Base32:
+		return DecodeSecretBytes(strings.ToUpper(secret))
+	case Hex:
+		return SecretFromHex(secret)
+	case Raw:
+		return []byte(secret), nil
+	default:
+		return nil, fmt.Errorf("%w: secret encoding '%s' not implemented", ErrInvalidSecret, encoding)
+	}
+}
+
+// escapes a component of the label so neither the path nor the issuer separator can be broken out of
+func escapeLabel(component string) string {
+	return strings.ReplaceAll(url.PathEscape(component), ":", "%3A")
+}
+
+/*
+** builds the Issuer:account label of an otpauth uri, with each component percent encoded so neither
+** can break out of the path or add a separator. The colon is left out when either one is empty
+ */
+func FormatLabel(issuer string, account string) string {
+	label := escapeLabel(account)
+
+	// with no account name the issuer is the whole label, so there is nothing to separate
+	if issuer != "" {
+		if label == "" {
+			return escapeLabel(issuer)
+		}
+
+		return fmt.Sprintf("%s:%s", escapeLabel(issuer), label)
+	}
+
+	return label
+}
+
+// the issuer set on the object wins over the package issuer from the ISSUER env
+func (hotp Hotp) issuerOrDefault() string {
+	if hotp.issuer != "" {
+		return hotp.issuer
+	}
+
+	return issuer
+}
 
 func (hotp Hotp) GenerateOtpAuthParams() string {
-	params := fmt.Sprintf("%s?secret=%s&algorithm=%s&counter=%d",
-		hotp.label,
-		EncodeSecret([]byte(hotp.secret)),
-		hotp.hashFunc,
-		hotp.counter,
-	)
+	return hotp.otpAuthParams(false)
+}
+
+func (hotp Hotp) otpAuthParams(pad bool) string {
+	return hotp.otpAuthParamsFor(hotp.issuerOrDefault(), hotp.label, pad)
+}
+
+/*
+** same as GenerateOtpAuthParams, but with the issuer and account passed in instead of read from the
+** object and the ISSUER env, so the output only depends on the arguments. An empty issuer leaves it out
+ */
+func (hotp Hotp) GenerateOtpAuthParamsFor(issuer string, account string) string {
+	return hotp.otpAuthParamsFor(issuer, account, false)
+}
+
+func (hotp Hotp) otpAuthParamsFor(issuer string, account string, pad bool) string {
+	secret := EncodeSecret(hotp.liveSecret())
+	if pad {
+		secret = base32.StdEncoding.EncodeToString(hotp.liveSecret())
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("algorithm", string(hotp.hashFunc))
+	query.Set("counter", formatURICounter(hotp.counter, hotp.hexCounter))
+	query.Set("digits", strconv.Itoa(hotp.digits))
+
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
 
-	if issuer == "" {
-		return params
+	labelIssuer := issuer
+	if hotp.omitIssuerLabel {
+		labelIssuer = ""
 	}
 
-	return fmt.Sprintf("%s:%s&issuer=%s", issuer, params, issuer)
+	return fmt.Sprintf("%s?%s", FormatLabel(labelIssuer, account), query.Encode())
 }
@@ -1,7 +1,21 @@
 package hotp
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,7 +40,7 @@ func TestHotpEightDigits(t *testing.T) {
 	digits := 8
 
 	for i := range counter {
-		hotp := CreateHotp(secret, i, digits)
+		hotp := CreateHotp(secret, i, digits, "")
 
 		code, err := hotp.Calculate()
 		assert.Nil(t, err)
@@ -35,6 +49,42 @@ func TestHotpEightDigits(t *testing.T) {
 	}
 }
 
+func TestHotpNineDigits(t *testing.T) {
+	// Sbits mod 10^9, rfc4226 appendix D only goes up to the full 10 digit value
+	var expectedCodes = map[uint64]string{
+		0: "284755224",
+		1: "094287082",
+		2: "137359152",
+		3: "726969429",
+		4: "640338314",
+		5: "868254676",
+		6: "918287922",
+		7: "082162583",
+		8: "673399871",
+		9: "645520489",
+	}
+
+	for counter, expected := range expectedCodes {
+		hotp := CreateHotp(secret, counter, 9, "")
+
+		code, err := hotp.Calculate()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+
+		validated, err := hotp.ValidateString(expected)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+	}
+
+	// a small truncated value is padded out to the full length
+	assert.Equal(t, "000000042", DecimalEncoder{Digits: 9}.Encode(42))
+	assert.Equal(t, "000000000", DecimalEncoder{Digits: 9}.Encode(0))
+	assert.Equal(t, "147483647", DecimalEncoder{Digits: 9}.Encode(math.MaxInt32))
+
+	_, err := CreateHotpChecked(secret, 0, maxDigits+1, "")
+	assert.ErrorIs(t, err, ErrInvalidDigits)
+}
+
 func TestHotpSevenDigits(t *testing.T) {
 	var expectedCodes = map[uint64]string{
 		0: "4755224",
@@ -53,7 +103,7 @@ func TestHotpSevenDigits(t *testing.T) {
 	digits := 7
 
 	for i := range counter {
-		hotp := CreateHotp(secret, i, digits)
+		hotp := CreateHotp(secret, i, digits, "")
 
 		code, err := hotp.Calculate()
 		assert.Nil(t, err)
@@ -80,7 +130,7 @@ func TestHotpSixDigits(t *testing.T) {
 	digits := 6
 
 	for i := range counter {
-		hotp := CreateHotp(secret, i, digits)
+		hotp := CreateHotp(secret, i, digits, "")
 
 		code, err := hotp.Calculate()
 		assert.Nil(t, err)
@@ -88,3 +138,2022 @@ func TestHotpSixDigits(t *testing.T) {
 		assert.Equal(t, expectedCodes[i], code)
 	}
 }
+
+func TestIssuerFromEnv(t *testing.T) {
+	defer func(original string) {
+		issuer = original
+	}(issuer)
+
+	hotp := CreateHotp(secret, 0, 6, "alice")
+
+	t.Setenv("ISSUER", "MyApplication")
+	issuer = issuerFromEnv()
+	params := hotp.GenerateOtpAuthParams()
+	assert.Contains(t, params, "MyApplication:alice?")
+	assert.Contains(t, params, "&issuer=MyApplication")
+
+	t.Setenv("ISSUER", "")
+	issuer = issuerFromEnv()
+	params = hotp.GenerateOtpAuthParams()
+	assert.Contains(t, params, "hotp:alice?")
+	assert.Contains(t, params, "&issuer=hotp")
+}
+
+func TestValidate(t *testing.T) {
+	validated, err := Validate(secret, 4, 6, 338314, sha1.New)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	validated, err = Validate(secret, 4, 6, 338315, sha1.New)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	// leading zeros are restored by the padding
+	validated, err = Validate(secret, 4, 7, 338314, sha1.New)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// a code longer than the digit count never matches
+	validated, err = Validate(secret, 4, 6, 40338314, sha1.New)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestInvalidDigits(t *testing.T) {
+	for _, digits := range []int{0, 10, -1} {
+		_, err := CalculateCode(secret, 0, digits, sha1.New)
+		assert.NotNil(t, err, "digits %d", digits)
+
+		_, err = Validate(secret, 0, digits, 0, sha1.New)
+		assert.NotNil(t, err, "digits %d", digits)
+
+		_, err = CreateHotpChecked(secret, 0, digits, "")
+		assert.NotNil(t, err, "digits %d", digits)
+
+		hotp := CreateHotp(secret, 0, digits, "")
+		_, err = hotp.Calculate()
+		assert.NotNil(t, err, "digits %d", digits)
+	}
+
+	hotp, err := CreateHotpChecked(secret, 0, 9, "")
+	assert.Nil(t, err)
+
+	_, err = hotp.Calculate()
+	assert.Nil(t, err)
+}
+
+func TestValidateLookAheadWindow(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	// code for counter 3
+	validated, err := hotp.Validate(969429)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+	validated, err = hotp.Validate(969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	assert.NotNil(t, hotp.SetLookAheadWindow(maxLookAheadSize+1))
+}
+
+func TestValidateLookBehindWindow(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+
+	// code for counter 3
+	validated, err := hotp.Validate(969429)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	// looking ahead doesn't help when the client is behind
+	assert.Nil(t, hotp.SetLookAheadWindow(maxLookAheadSize))
+	validated, err = hotp.Validate(969429)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	assert.Nil(t, hotp.SetLookBehindWindow(2))
+	validated, err = hotp.Validate(969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	assert.NotNil(t, hotp.SetLookBehindWindow(maxLookAheadSize+1))
+}
+
+func TestValidateLookBehindWindowRejectsReplay(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+	assert.Nil(t, hotp.SetLookBehindWindow(1))
+
+	// code for counter 5, then again when it is one behind the counter
+	validated, err := hotp.Validate(254676)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(6), hotp.GetCounter())
+
+	for range 2 {
+		validated, err = hotp.Validate(254676)
+		assert.Nil(t, err)
+		assert.False(t, validated)
+		assert.Equal(t, uint64(6), hotp.GetCounter())
+	}
+
+	// counters skipped by a later accepted code can't be used either, counter 4 is behind 5
+	hotp.SetCounter(5)
+	validated, err = hotp.Validate(338314)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	// a counter that was never consumed still matches behind, e.g. after the server moved on
+	hotp.SetCounter(7)
+	validated, err = hotp.Validate(287922)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(7), hotp.GetCounter())
+
+	lastCounter, _ := hotp.GetLastValidatedCounter()
+	assert.Equal(t, uint64(6), lastCounter)
+}
+
+func TestValidateLookBehindWindowNearZero(t *testing.T) {
+	hotp := CreateHotp(secret, 1, 6, "")
+	assert.Nil(t, hotp.SetLookBehindWindow(maxLookAheadSize))
+
+	// code for counter 0
+	validated, err := hotp.Validate(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+
+	// nothing below 0 to match, and the counter must not wrap around
+	hotp.SetCounter(0)
+	validated, err = hotp.Validate(111111)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+}
+
+func TestNegativeWindows(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	assert.ErrorIs(t, hotp.SetLookAheadWindow(-1), ErrNegativeWindow)
+	assert.ErrorIs(t, hotp.SetLookBehindWindow(-1), ErrNegativeWindow)
+	assert.ErrorIs(t, hotp.SetResyncWindow(-1), ErrNegativeWindow)
+	assert.Equal(t, 0, hotp.GetLookAheadWindow())
+	assert.Equal(t, 0, hotp.GetLookBehindWindow())
+
+	_, err := NewHotp(secret, WithLookAhead(-1))
+	assert.ErrorIs(t, err, ErrNegativeWindow)
+
+	// the windows stayed at 0, so a wrong code is a single comparison instead of a wrapped around loop
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		validated, err := hotp.Validate(111111)
+		assert.Nil(t, err)
+		assert.False(t, validated)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("validation did not finish")
+	}
+}
+
+func TestDecodeSecretBytes(t *testing.T) {
+	for _, length := range []int{10, 16, 20, 32} {
+		secret := GenerateSecret(length)
+
+		decoded, err := DecodeSecretBytes(EncodeSecret(secret))
+		assert.Nil(t, err)
+		assert.Equal(t, secret, decoded)
+	}
+
+	// bytes that aren't valid utf-8 still survive the round trip
+	binarySecret := []byte{0xff, 0xfe, 0x00, 0x80, 0xc3}
+	decoded, err := DecodeSecretBytes(EncodeSecret(binarySecret))
+	assert.Nil(t, err)
+	assert.Equal(t, binarySecret, decoded)
+
+	_, err = DecodeSecretBytes("not base32!")
+	assert.NotNil(t, err)
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	for length := 10; length <= 20; length++ {
+		secret := GenerateSecret(length)
+
+		decoded, err := DecodeSecret(EncodeSecret(secret))
+		assert.Nil(t, err, "length %d", length)
+		assert.Equal(t, string(secret), decoded, "length %d", length)
+
+		// padded secrets from other sources decode to the same value
+		padded := base32.StdEncoding.EncodeToString(secret)
+		decoded, err = DecodeSecret(padded)
+		assert.Nil(t, err, "length %d", length)
+		assert.Equal(t, string(secret), decoded, "length %d", length)
+	}
+}
+
+func TestGetters(t *testing.T) {
+	hotp := CreateHotp(secret, 3, 8, "alice")
+	assert.Nil(t, hotp.SetHashFunc(SHA512))
+	assert.Nil(t, hotp.SetLookAheadWindow(4))
+	assert.Nil(t, hotp.SetLookBehindWindow(2))
+
+	assert.Equal(t, uint64(3), hotp.GetCounter())
+	assert.Equal(t, 8, hotp.GetDigits())
+	assert.Equal(t, SHA512, hotp.GetHashFunc())
+	assert.Equal(t, 4, hotp.GetLookAheadWindow())
+	assert.Equal(t, 2, hotp.GetLookBehindWindow())
+	assert.True(t, hotp.HasSecret())
+
+	assert.False(t, CreateHotp("", 0, 6, "").HasSecret())
+}
+
+func TestCalculateRange(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	codes, err := hotp.CalculateRange(10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}, codes)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	hotp.SetCounter(8)
+	codes, err = hotp.CalculateRange(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"399871", "520489"}, codes)
+
+	_, err = hotp.CalculateRange(0)
+	assert.NotNil(t, err)
+
+	_, err = hotp.CalculateRange(maxRangeSize + 1)
+	assert.NotNil(t, err)
+
+	hotp.SetCounter(math.MaxUint64)
+	_, err = hotp.CalculateRange(2)
+	assert.NotNil(t, err)
+}
+
+func TestValidateWithDelta(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(5))
+
+	// exact match on counter 0
+	validated, delta, err := hotp.ValidateWithDelta(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(0), delta)
+
+	// code for counter 4, three ahead of the current counter of 1
+	validated, delta, err = hotp.ValidateWithDelta(338314)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(3), delta)
+
+	validated, delta, err = hotp.ValidateWithDelta(111111)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(0), delta)
+}
+
+func TestGenerateSecretChecked(t *testing.T) {
+	secret, err := GenerateSecretChecked(DefaultSecretLength)
+	assert.Nil(t, err)
+	assert.Len(t, secret, DefaultSecretLength)
+
+	secret, err = GenerateSecretChecked(minSecretLength)
+	assert.Nil(t, err)
+	assert.Len(t, secret, minSecretLength)
+
+	for _, length := range []int{-1, 0, 1, minSecretLength - 1} {
+		_, err = GenerateSecretChecked(length)
+		assert.NotNil(t, err, "length %d", length)
+	}
+}
+
+func TestGenerateSecretFrom(t *testing.T) {
+	reader := bytes.NewReader([]byte("12345678901234567890extra"))
+
+	generated, err := GenerateSecretFrom(reader, DefaultSecretLength)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(secret), generated)
+
+	// the reader runs out before the secret is complete
+	_, err = GenerateSecretFrom(reader, DefaultSecretLength)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = GenerateSecretFrom(reader, -1)
+	assert.NotNil(t, err)
+
+	generated, err = GenerateSecretFrom(bytes.NewReader(nil), 0)
+	assert.Nil(t, err)
+	assert.Empty(t, generated)
+}
+
+// hands out 0, 1, 2, ... and is not safe for concurrent use
+type sequenceReader struct {
+	next byte
+}
+
+func (reader *sequenceReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = reader.next
+		reader.next++
+	}
+
+	return len(p), nil
+}
+
+func TestSecretGeneratorConcurrent(t *testing.T) {
+	generator := NewSecretGenerator(&sequenceReader{})
+
+	const goroutines = 16
+	secrets := make([][]byte, goroutines)
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			generated, err := generator.Generate(minSecretLength)
+			assert.Nil(t, err)
+			secrets[i] = generated
+		}()
+	}
+	wg.Wait()
+
+	// every secret is a run of consecutive bytes, which breaks if two reads interleave
+	seen := map[byte]bool{}
+	for _, generated := range secrets {
+		assert.Len(t, generated, minSecretLength)
+
+		for i := range generated {
+			assert.Equal(t, generated[0]+byte(i), generated[i])
+		}
+
+		assert.False(t, seen[generated[0]], "secret starting at %d handed out twice", generated[0])
+		seen[generated[0]] = true
+	}
+
+	_, err := generator.Generate(-1)
+	assert.NotNil(t, err)
+}
+
+// hands out zeros forever and counts how often it was read from
+type zeroReader struct {
+	reads int
+}
+
+func (reader *zeroReader) Read(p []byte) (int, error) {
+	reader.reads++
+	clear(p)
+
+	return len(p), nil
+}
+
+func TestGenerateSecretCheckedWeak(t *testing.T) {
+	defer func(original io.Reader) {
+		randReader = original
+	}(randReader)
+
+	zeros := &zeroReader{}
+	randReader = zeros
+
+	_, err := GenerateSecretChecked(DefaultSecretLength)
+	assert.ErrorIs(t, err, ErrWeakSecret)
+	assert.Equal(t, maxSecretAttempts, zeros.reads)
+
+	// an all zero buffer first, then a usable one
+	randReader = io.MultiReader(bytes.NewReader(make([]byte, DefaultSecretLength)), bytes.NewReader([]byte(secret)))
+
+	generated, err := GenerateSecretChecked(DefaultSecretLength)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(secret), generated)
+
+	assert.True(t, isWeakSecret(bytes.Repeat([]byte{0xaa}, 20)))
+	assert.False(t, isWeakSecret([]byte(secret)))
+}
+
+func TestConcurrentValidate(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(2))
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+
+	// every goroutine submits the code for counter 0, only one of them may be accepted
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			validated, err := hotp.Validate(755224)
+			assert.Nil(t, err)
+
+			if validated {
+				accepted.Add(1)
+			}
+
+			// a locked read, -race would flag GetCounter here
+			assert.LessOrEqual(t, hotp.Counter(), uint64(1))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), accepted.Load())
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+	assert.Equal(t, uint64(1), hotp.Counter())
+}
+
+func TestZeroValueHotp(t *testing.T) {
+	var hotp Hotp
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	hotp.SetCounter(3)
+	assert.Equal(t, uint64(3), hotp.GetCounter())
+
+	// nothing to validate with, but no panic either
+	validated, err := hotp.Validate(755224)
+	assert.NotNil(t, err)
+	assert.False(t, validated)
+}
+
+func TestGetCounterOnReturnedValue(t *testing.T) {
+	// not addressable, which only compiles with a value receiver
+	assert.Equal(t, uint64(3), CreateHotp(secret, 3, 6, "").GetCounter())
+}
+
+func TestCalculateCodeWith(t *testing.T) {
+	code, err := CalculateCodeWith(secret, 1, 6, SHA1)
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", code)
+
+	// counter 1 is the same as the rfc6238 vectors at 59 seconds
+	code, err = CalculateCodeWith(totpSecrets[SHA256], 1, 8, SHA256)
+	assert.Nil(t, err)
+	assert.Equal(t, "46119246", code)
+
+	code, err = CalculateCodeWith(totpSecrets[SHA512], 1, 8, SHA512)
+	assert.Nil(t, err)
+	assert.Equal(t, "90693936", code)
+
+	_, err = CalculateCodeWith(secret, 1, 6, HashFunc("md5"))
+	assert.NotNil(t, err)
+}
+
+func TestCreateHotpFromBase32(t *testing.T) {
+	// base32 of the rfc4226 secret
+	hotp, err := CreateHotpFromBase32("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 0, 6, "")
+	assert.Nil(t, err)
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+
+	// reference values from pyotp for the same secret
+	hotp, err = CreateHotpFromBase32("base32secret3232", 0, 6, "")
+	assert.Nil(t, err)
+
+	codes, err := hotp.CalculateRange(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"260182", "055283"}, codes)
+
+	hotp.SetCounter(1401)
+	code, err = hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "316439", code)
+
+	_, err = CreateHotpFromBase32("not base32!", 0, 6, "")
+	assert.NotNil(t, err)
+}
+
+func TestCalculateSteam(t *testing.T) {
+	// Sbits for counter 0 and 1 are 1284755224 and 1094287082 (rfc4226 appendix D)
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	code, err := hotp.CalculateSteam()
+	assert.Nil(t, err)
+	assert.Equal(t, "GG5F5", code)
+
+	hotp.IncrementCounter()
+	code, err = hotp.CalculateSteam()
+	assert.Nil(t, err)
+	assert.Equal(t, "PV9M4", code)
+}
+
+func TestReset(t *testing.T) {
+	hotp := CreateHotp(secret, 42, 6, "")
+
+	hotp.Reset()
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+}
+
+func TestRotateSecret(t *testing.T) {
+	hotp := CreateHotp("old secret", 42, 6, "")
+
+	hotp.RotateSecret(secret)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+
+	for _, code := range []int{755224, 287082, 359152, 969429, 338314} {
+		validated, err := hotp.VerifyAndAdvance(code)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+	}
+
+	// the counters consumed under the old secret don't hold back the new one
+	hotp.RotateSecret(secret)
+	validated, err := hotp.VerifyAndAdvance(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	last, ok := hotp.GetLastValidatedCounter()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), last)
+}
+
+func TestCalculateInt(t *testing.T) {
+	hotp := CreateHotp(secret, 4, 7, "")
+
+	code, err := hotp.CalculateInt()
+	assert.Nil(t, err)
+	assert.Equal(t, 338314, code)
+
+	formatted, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "0338314", formatted)
+	assert.Equal(t, formatted, formatCode(code, hotp.GetDigits()))
+
+	hotp = CreateHotp(secret, 4, 0, "")
+	_, err = hotp.CalculateInt()
+	assert.NotNil(t, err)
+}
+
+func TestValidateContext(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(5))
+
+	validated, err := hotp.ValidateContext(context.Background(), 755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the exact match on counter 1 doesn't need the window, so it still works
+	validated, err = hotp.ValidateContext(ctx, 287082)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// code for counter 4 would have matched in the window
+	validated, err = hotp.ValidateContext(ctx, 338314)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(2), hotp.GetCounter())
+}
+
+func TestStringRedactsSecret(t *testing.T) {
+	hotp := CreateHotp(secret, 3, 8, "alice")
+	assert.Nil(t, hotp.SetLookAheadWindow(2))
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		output := fmt.Sprintf(format, hotp)
+		assert.NotContains(t, output, secret, format)
+		assert.NotContains(t, output, EncodeSecret([]byte(secret)), format)
+		assert.Contains(t, output, "secret=<redacted>", format)
+	}
+
+	output := hotp.String()
+	assert.Contains(t, output, "counter=3")
+	assert.Contains(t, output, "digits=8")
+	assert.Contains(t, output, "algorithm=sha1")
+	assert.Contains(t, output, "lookAheadWindow=2")
+
+	// pointers format the same way
+	assert.NotContains(t, fmt.Sprintf("%v", &hotp), secret)
+}
+
+func TestSecretEncodings(t *testing.T) {
+	// the rfc4226 secret in every encoding
+	encoded := map[SecretEncoding]string{
+		Base32: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		Hex:    "3132333435363738393031323334353637383930",
+		Raw:    secret,
+	}
+
+	for encoding, encodedSecret := range encoded {
+		decoded, err := DecodeSecretAs(encodedSecret, encoding)
+		assert.Nil(t, err, encoding)
+		assert.Equal(t, []byte(secret), decoded, encoding)
+
+		hotp, err := CreateHotpWithEncoding(encodedSecret, encoding, 0, 6, "")
+		assert.Nil(t, err, encoding)
+
+		code, err := hotp.Calculate()
+		assert.Nil(t, err, encoding)
+		assert.Equal(t, "755224", code, encoding)
+	}
+
+	decoded, err := SecretFromHex(encoded[Hex])
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(secret), decoded)
+
+	_, err = SecretFromHex("not hex")
+	assert.NotNil(t, err)
+
+	_, err = DecodeSecretAs(secret, SecretEncoding("base64"))
+	assert.NotNil(t, err)
+}
+
+func TestVerifyAndAdvanceRejectsReplay(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	_, validated := hotp.GetLastValidatedCounter()
+	assert.False(t, validated)
+
+	validated, err := hotp.VerifyAndAdvance(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	lastCounter, validated := hotp.GetLastValidatedCounter()
+	assert.True(t, validated)
+	assert.Equal(t, uint64(0), lastCounter)
+
+	validated, err = hotp.VerifyAndAdvance(755224)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestVerifyAndAdvanceRejectsReplayInWindow(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(5))
+
+	// code for counter 3, accepted through the look ahead window
+	validated, err := hotp.VerifyAndAdvance(969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	lastCounter, _ := hotp.GetLastValidatedCounter()
+	assert.Equal(t, uint64(3), lastCounter)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	validated, err = hotp.VerifyAndAdvance(969429)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestVerifyAndAdvanceRejectsReplayBehind(t *testing.T) {
+	for name, setWindow := range map[string]func(*Hotp) error{
+		"look behind": func(hotp *Hotp) error { return hotp.SetLookBehindWindow(2) },
+		"resync":      func(hotp *Hotp) error { return hotp.SetResyncWindow(2) },
+	} {
+		hotp := CreateHotp(secret, 5, 6, "")
+		assert.Nil(t, setWindow(&hotp), name)
+
+		// code for counter 5, one behind the counter once it is accepted
+		validated, err := hotp.VerifyAndAdvance(254676)
+		assert.Nil(t, err, name)
+		assert.True(t, validated, name)
+
+		for range 2 {
+			validated, err = hotp.VerifyAndAdvance(254676)
+			assert.Nil(t, err, name)
+			assert.False(t, validated, name)
+			assert.Equal(t, uint64(6), hotp.GetCounter(), name)
+		}
+
+		// even with the counter moved back onto it
+		hotp.SetCounter(5)
+		validated, err = hotp.VerifyAndAdvance(254676)
+		assert.Nil(t, err, name)
+		assert.False(t, validated, name)
+		assert.Equal(t, uint64(5), hotp.GetCounter(), name)
+	}
+}
+
+func TestVerifyAndAdvanceWithoutAutoAdvance(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+	hotp.SetAutoAdvance(false)
+
+	validated, err := hotp.VerifyAndAdvance(254676)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// Validate accepts it again as the counter didn't move, VerifyAndAdvance doesn't
+	validated, err = hotp.VerifyAndAdvance(254676)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+// registers factory for the length of the test, the registry is global so each test uses its own name
+func registerTestHashFunc(t *testing.T, name HashFunc, factory func() hash.Hash) {
+	t.Helper()
+
+	assert.Nil(t, RegisterHashFunc(name, factory))
+	t.Cleanup(func() {
+		hashRegistryMu.Lock()
+		defer hashRegistryMu.Unlock()
+
+		delete(hashRegistry, name)
+	})
+}
+
+func TestRegisterHashFunc(t *testing.T) {
+	sha224 := HashFunc("sha224-register")
+
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.NotNil(t, hotp.SetHashFunc(sha224))
+
+	registerTestHashFunc(t, sha224, sha256.New224)
+	assert.Nil(t, hotp.SetHashFunc(sha224))
+	assert.Equal(t, sha224, hotp.GetHashFunc())
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+
+	expected, err := CalculateCode(secret, 0, 6, sha256.New224)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	sha1Code, err := CalculateCode(secret, 0, 6, sha1.New)
+	assert.Nil(t, err)
+	assert.NotEqual(t, sha1Code, code)
+
+	assert.NotNil(t, RegisterHashFunc(HashFunc("nil"), nil))
+	assert.NotNil(t, RegisterHashFunc(SHA1, sha256.New))
+}
+
+func TestGenerateSecretBase32(t *testing.T) {
+	for _, length := range []int{minSecretLength, DefaultSecretLength, 32} {
+		encoded, err := GenerateSecretBase32(length)
+		assert.Nil(t, err)
+
+		decoded, err := DecodeSecretBytes(encoded)
+		assert.Nil(t, err)
+		assert.Len(t, decoded, length)
+	}
+
+	_, err := GenerateSecretBase32(minSecretLength - 1)
+	assert.NotNil(t, err)
+}
+
+func TestEmptySecret(t *testing.T) {
+	_, err := CalculateCode("", 0, 6, sha1.New)
+	assert.EqualError(t, err, "secret must not be empty")
+
+	_, err = Validate("", 0, 6, 755224, sha1.New)
+	assert.NotNil(t, err)
+
+	_, err = CreateHotpChecked("", 0, 6, "")
+	assert.NotNil(t, err)
+
+	_, err = NewHotp("")
+	assert.NotNil(t, err)
+
+	hotp := CreateHotp("", 0, 6, "")
+	_, err = hotp.Calculate()
+	assert.NotNil(t, err)
+
+	validated, err := hotp.Validate(755224)
+	assert.NotNil(t, err)
+	assert.False(t, validated)
+}
+
+func TestShortHashRejected(t *testing.T) {
+	// md5 is only 16 bytes, too short for the truncation offset
+	_, err := CalculateCode(secret, 0, 6, md5.New)
+	assert.NotNil(t, err)
+
+	registerTestHashFunc(t, HashFunc("md5-truncation"), md5.New)
+
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetHashFunc(HashFunc("md5-truncation")))
+
+	for counter := range uint64(20) {
+		hotp.SetCounter(counter)
+
+		_, err = hotp.Calculate()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestValidateWithResync(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+	assert.Nil(t, hotp.SetResyncWindow(3))
+
+	// code for counter 3, behind the server
+	validated, delta, err := hotp.ValidateWithResync(969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(-2), delta)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	// code for counter 7, ahead of the server
+	validated, delta, err = hotp.ValidateWithResync(162583)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(3), delta)
+	assert.Equal(t, uint64(8), hotp.GetCounter())
+
+	// exact matches are preferred and report no drift
+	validated, delta, err = hotp.ValidateWithResync(399871)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(0), delta)
+
+	// code for counter 4 is outside the window
+	validated, _, err = hotp.ValidateWithResync(338314)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	assert.NotNil(t, hotp.SetResyncWindow(maxLookAheadSize+1))
+}
+
+func TestValidateWithResyncRejectsReplay(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+	assert.Nil(t, hotp.SetResyncWindow(1))
+
+	// code for counter 5, the resync window would find it one behind on every retry
+	validated, delta, err := hotp.ValidateWithResync(254676)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(0), delta)
+
+	for range 2 {
+		validated, _, err = hotp.ValidateWithResync(254676)
+		assert.Nil(t, err)
+		assert.False(t, validated)
+		assert.Equal(t, uint64(6), hotp.GetCounter())
+	}
+
+	// code for counter 7, one ahead, then replayed one behind
+	validated, delta, err = hotp.ValidateWithResync(162583)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(1), delta)
+
+	validated, _, err = hotp.ValidateWithResync(162583)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(8), hotp.GetCounter())
+}
+
+func TestValidateWithResyncNearZero(t *testing.T) {
+	hotp := CreateHotp(secret, 1, 6, "")
+	assert.Nil(t, hotp.SetResyncWindow(maxLookAheadSize))
+
+	// code for counter 0
+	validated, delta, err := hotp.ValidateWithResync(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(-1), delta)
+
+	hotp.SetCounter(0)
+	validated, _, err = hotp.ValidateWithResync(111111)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+}
+
+func TestClone(t *testing.T) {
+	original := CreateHotp(secret, 0, 6, "alice")
+	assert.Nil(t, original.SetLookAheadWindow(2))
+
+	clone := original.Clone()
+
+	// a dry run on the clone leaves the original untouched
+	validated, err := clone.Validate(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(1), clone.GetCounter())
+	assert.Equal(t, uint64(0), original.GetCounter())
+
+	clone.SetLabel("bob")
+	assert.Nil(t, clone.SetHashFunc(SHA256))
+	assert.Equal(t, "alice", original.GetLabel())
+	assert.Equal(t, SHA1, original.GetHashFunc())
+
+	assert.NotSame(t, original.mu, clone.mu)
+
+	code, err := clone.Calculate()
+	assert.Nil(t, err)
+	assert.NotEqual(t, "287082", code)
+
+	code, err = original.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+}
+
+func TestSyncCounterToTime(t *testing.T) {
+	defer func(original func() time.Time) {
+		timeNow = original
+	}(timeNow)
+
+	timeNow = func() time.Time {
+		return time.Unix(1111111109, 0)
+	}
+
+	hotp := CreateHotp(secret, 0, 8, "")
+
+	assert.Nil(t, hotp.SyncCounterToTime(30))
+	assert.Equal(t, uint64(37037036), hotp.GetCounter())
+
+	// the same as the rfc6238 vector for this time
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "07081804", code)
+
+	assert.Nil(t, hotp.SyncCounterToTime(3600))
+	assert.Equal(t, uint64(308641), hotp.GetCounter())
+
+	assert.NotNil(t, hotp.SyncCounterToTime(0))
+	assert.Equal(t, uint64(308641), hotp.GetCounter())
+
+	// a negative unix time would wrap around to a huge counter
+	timeNow = func() time.Time {
+		return time.Unix(-30, 0)
+	}
+
+	assert.NotNil(t, hotp.SyncCounterToTime(30))
+	assert.Equal(t, uint64(308641), hotp.GetCounter())
+}
+
+func TestHashFuncText(t *testing.T) {
+	valid := map[string]HashFunc{
+		"SHA1":   SHA1,
+		"sha1":   SHA1,
+		"sha256": SHA256,
+		"Sha512": SHA512,
+	}
+
+	for text, expected := range valid {
+		var hashFunc HashFunc
+		assert.Nil(t, hashFunc.UnmarshalText([]byte(text)), text)
+		assert.Equal(t, expected, hashFunc, text)
+
+		marshaled, err := hashFunc.MarshalText()
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(marshaled))
+	}
+
+	var hashFunc HashFunc
+	assert.NotNil(t, hashFunc.UnmarshalText([]byte("md5")))
+	assert.Equal(t, HashFunc(""), hashFunc)
+
+	// works through config formats like json
+	var config struct {
+		Algorithm HashFunc `json:"algorithm"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(`{"algorithm":"SHA256"}`), &config))
+	assert.Equal(t, SHA256, config.Algorithm)
+	assert.NotNil(t, json.Unmarshal([]byte(`{"algorithm":"rot13"}`), &config))
+}
+
+func TestParseHashFunc(t *testing.T) {
+	valid := map[string]HashFunc{
+		"sha1":    SHA1,
+		"SHA1":    SHA1,
+		"Sha1":    SHA1,
+		"SHA-1":   SHA1,
+		"sha256":  SHA256,
+		"SHA256":  SHA256,
+		"Sha-256": SHA256,
+		"sha512":  SHA512,
+		"SHA512":  SHA512,
+		"SHA-512": SHA512,
+	}
+
+	for name, expected := range valid {
+		hashFunc, err := ParseHashFunc(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, expected, hashFunc, name)
+
+		hotp := CreateHotp(secret, 0, 6, "")
+		assert.Nil(t, hotp.SetHashFunc(HashFunc(name)), name)
+		assert.Equal(t, expected, hotp.GetHashFunc(), name)
+	}
+
+	_, err := ParseHashFunc("md5")
+	assert.NotNil(t, err)
+
+	_, err = ParseHashFunc("")
+	assert.NotNil(t, err)
+}
+
+func TestValidateString(t *testing.T) {
+	hotp := CreateHotp(secret, 4, 7, "")
+
+	// the int form would lose the leading zero
+	validated, err := hotp.ValidateString("338314")
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	validated, err = hotp.ValidateString("0338314")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+
+	// the look ahead window still applies, code for counter 6
+	assert.Nil(t, hotp.SetLookAheadWindow(2))
+	validated, err = hotp.ValidateString("8287922")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(7), hotp.GetCounter())
+
+	validated, err = hotp.ValidateString("")
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestValidateCode(t *testing.T) {
+	hotp := CreateHotp(secret, 4, 7, "")
+
+	// the leading zero is kept, and whitespace around the code is dropped
+	validated, err := hotp.ValidateCode(" 0338314\n")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+
+	// a well formed code that doesn't match
+	validated, err = hotp.ValidateCode("0000000")
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, 1, hotp.GetFailures())
+
+	for _, code := range []string{"", "338314", "00338314", "03383I4", "-338314", "0338 314"} {
+		_, err = hotp.ValidateCode(code)
+		assert.ErrorIs(t, err, ErrInvalidCode, code)
+	}
+
+	assert.Equal(t, 1, hotp.GetFailures())
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+}
+
+func TestIncrementCounterChecked(t *testing.T) {
+	hotp := CreateHotp(secret, math.MaxUint64-1, 6, "")
+
+	assert.Nil(t, hotp.IncrementCounterChecked())
+	assert.Equal(t, uint64(math.MaxUint64), hotp.GetCounter())
+
+	assert.NotNil(t, hotp.IncrementCounterChecked())
+	assert.Equal(t, uint64(math.MaxUint64), hotp.GetCounter())
+}
+
+func TestValidateAtCounterLimit(t *testing.T) {
+	hotp := CreateHotp(secret, math.MaxUint64, 6, "")
+
+	code, err := hotp.CalculateInt()
+	assert.Nil(t, err)
+
+	validated, err := hotp.Validate(code)
+	assert.NotNil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(math.MaxUint64), hotp.GetCounter())
+
+	// the window doesn't run past the last counter either
+	hotp.SetCounter(math.MaxUint64 - 2)
+	assert.Nil(t, hotp.SetLookAheadWindow(maxLookAheadSize))
+
+	validated, err = hotp.Validate(code)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(math.MaxUint64-2), hotp.GetCounter())
+}
+
+func TestValidateAny(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+
+	// nothing matches, the counter stays put
+	validated, _, err := hotp.ValidateAny([]int{111111, 222222})
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	// 359152 is counter 2 and 969429 counter 3, only the first accepted code moves the counter
+	validated, matched, err := hotp.ValidateAny([]int{111111, 359152, 969429})
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(2), matched)
+	assert.Equal(t, uint64(3), hotp.GetCounter())
+
+	// 520489 is counter 9, outside of the window
+	validated, matched, err = hotp.ValidateAny([]int{520489, 338314})
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(4), matched)
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+
+	validated, _, err = hotp.ValidateAny(nil)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestDynamicTruncate(t *testing.T) {
+	// the Truncated column of rfc4226 appendix D
+	truncated := []int32{
+		1284755224,
+		1094287082,
+		137359152,
+		1726969429,
+		1640338314,
+		868254676,
+		1918287922,
+		82162583,
+		673399871,
+		645520489,
+	}
+
+	for counter, expected := range truncated {
+		Sbits, err := DynamicTruncate(secret, uint64(counter), SHA1)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, Sbits, "counter %d", counter)
+	}
+
+	_, err := DynamicTruncate(secret, 0, HashFunc("md5"))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, err = DynamicTruncate("", 0, SHA1)
+	assert.ErrorIs(t, err, ErrEmptySecret)
+}
+
+func TestTruncationOffset(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Equal(t, -1, hotp.GetTruncationOffset())
+
+	dynamic, err := hotp.CalculateRange(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"755224", "287082", "359152"}, dynamic)
+
+	assert.Nil(t, hotp.SetTruncationOffset(4))
+	assert.Equal(t, 4, hotp.GetTruncationOffset())
+
+	// the hmac for counter 2 has a dynamic offset of 4 already, so only that code is the same
+	fixed, err := hotp.CalculateRange(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"455891", "647552", "359152"}, fixed)
+
+	validated, err := hotp.Validate(455891)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	assert.NotNil(t, hotp.SetTruncationOffset(16))
+	assert.NotNil(t, hotp.SetTruncationOffset(-2))
+	assert.Equal(t, 4, hotp.GetTruncationOffset())
+
+	// survives a snapshot
+	restored, err := RestoreHotp(hotp.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, 4, restored.GetTruncationOffset())
+
+	assert.Nil(t, hotp.SetTruncationOffset(-1))
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", code)
+}
+
+func TestCounterBytes(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Equal(t, 8, hotp.GetCounterBytes())
+
+	eightBytes, err := hotp.CalculateRange(4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"755224", "287082", "359152", "969429"}, eightBytes)
+
+	// the hmac of a 4 byte big endian counter
+	assert.Nil(t, hotp.SetCounterBytes(4))
+	assert.Equal(t, 4, hotp.GetCounterBytes())
+
+	fourBytes, err := hotp.CalculateRange(4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"613114", "675152", "823798", "343430"}, fourBytes)
+
+	validated, err := hotp.Validate(613114)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+
+	// survives a snapshot
+	restored, err := RestoreHotp(hotp.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, 4, restored.GetCounterBytes())
+	assert.True(t, hotp.Equal(*restored))
+
+	// a counter 4 bytes can't hold
+	hotp.SetCounter(math.MaxUint32 + 1)
+	_, err = hotp.Calculate()
+	assert.ErrorIs(t, err, ErrCounterExhausted)
+
+	assert.NotNil(t, hotp.SetCounterBytes(2))
+	assert.NotNil(t, hotp.SetCounterBytes(0))
+	assert.Equal(t, 4, hotp.GetCounterBytes())
+
+	assert.Nil(t, hotp.SetCounterBytes(8))
+	hotp.SetCounter(0)
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+}
+
+func TestValidateHooks(t *testing.T) {
+	hotp := CreateHotp(secret, 2, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+	assert.Nil(t, hotp.SetLookBehindWindow(2))
+
+	// no hooks set is fine
+	validated, err := hotp.Validate(111111)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	var deltas []uint64
+	failures := 0
+	hotp.OnValidateSuccess(func(delta uint64) {
+		deltas = append(deltas, delta)
+	})
+	hotp.OnValidateFailure(func() {
+		failures++
+	})
+
+	// counter 2 exactly, then counter 5 two ahead of 3
+	for _, code := range []int{359152, 254676} {
+		_, err := hotp.Validate(code)
+		assert.Nil(t, err)
+	}
+
+	// the server moves on to 8, then counter 6 two behind it, leaving it at 7
+	hotp.IncrementCounter()
+	hotp.IncrementCounter()
+	for _, code := range []int{287922, 111111} {
+		_, err := hotp.Validate(code)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []uint64{0, 2, 2}, deltas)
+	assert.Equal(t, 1, failures)
+
+	// the hooks can use the object, they run after the lock is released
+	hotp.OnValidateSuccess(func(delta uint64) {
+		assert.Equal(t, uint64(8), hotp.GetCounter())
+	})
+
+	// counter 1 is outside the look behind window, counter 7 is the current one
+	validated, _, err = hotp.ValidateAny([]int{111111, 287082})
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, 2, failures)
+
+	validated, _, err = hotp.ValidateAny([]int{111111, 162583})
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
+
+func TestReduceNeverNegative(t *testing.T) {
+	// the top of the int32 range
+	assert.Equal(t, 483647, reduce(math.MaxInt32, 6))
+	assert.Equal(t, 147483647, reduce(math.MaxInt32, 9))
+
+	// past maxDigits the modulo is bigger than any int32, so the value comes back as is
+	assert.Equal(t, math.MaxInt32, reduce(math.MaxInt32, 10))
+
+	// can't come out of dynamic truncation, but is still kept in range
+	assert.Equal(t, 999999, reduce(-1, 6))
+	assert.Equal(t, 516352, reduce(math.MinInt32, 6))
+
+	for digits := minDigits; digits <= maxDigits; digits++ {
+		modulo := int(math.Pow10(digits))
+
+		for counter := range uint64(2000) {
+			code, err := calculateInt([]byte(secret), counter, digits, sha1.New)
+			assert.Nil(t, err)
+
+			if code < 0 || code >= modulo {
+				t.Fatalf("code %d for counter %d is outside [0, %d)", code, counter, modulo)
+			}
+		}
+	}
+}
+
+func TestCreateHotpBytes(t *testing.T) {
+	key := []byte(secret)
+
+	fromString := CreateHotp(secret, 0, 6, "")
+	fromBytes := CreateHotpBytes(key, 0, 6)
+
+	for counter := range uint64(10) {
+		fromString.SetCounter(counter)
+		fromBytes.SetCounter(counter)
+
+		expected, err := fromString.Calculate()
+		assert.Nil(t, err)
+
+		code, err := fromBytes.Calculate()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+
+		code, err = CalculateCodeBytes(key, counter, 6, sha1.New)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	// the key is copied, wiping the caller's slice doesn't change the object or its clones
+	clone := fromBytes.Clone()
+	for i := range key {
+		key[i] = 0
+	}
+
+	code, err := fromBytes.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "520489", code)
+
+	code, err = clone.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "520489", code)
+
+	_, err = CalculateCodeBytes(nil, 0, 6, sha1.New)
+	assert.ErrorIs(t, err, ErrEmptySecret)
+}
+
+func TestMaxFailures(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetMaxFailures(3))
+	assert.NotNil(t, hotp.SetMaxFailures(-1))
+
+	// a success in between resets the count
+	for _, code := range []int{111111, 111111, 755224} {
+		_, err := hotp.Validate(code)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 0, hotp.GetFailures())
+
+	for range 3 {
+		validated, err := hotp.Validate(111111)
+		assert.Nil(t, err)
+		assert.False(t, validated)
+	}
+	assert.Equal(t, 3, hotp.GetFailures())
+
+	// 287082 is the correct code for counter 1, but the token is locked out
+	validated, err := hotp.Validate(287082)
+	assert.ErrorIs(t, err, ErrLockedOut)
+	assert.False(t, validated)
+
+	_, _, err = hotp.ValidateAny([]int{287082})
+	assert.ErrorIs(t, err, ErrLockedOut)
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+
+	// the lockout survives a snapshot
+	restored, err := RestoreHotp(hotp.Snapshot())
+	assert.Nil(t, err)
+	_, err = restored.Validate(287082)
+	assert.ErrorIs(t, err, ErrLockedOut)
+
+	hotp.ResetFailures()
+	validated, err = hotp.Validate(287082)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// 0 is no limit
+	assert.Nil(t, hotp.SetMaxFailures(0))
+	for range 5 {
+		_, err := hotp.Validate(111111)
+		assert.Nil(t, err)
+	}
+}
+
+func TestValidateAt(t *testing.T) {
+	hotp := CreateHotp(secret, 3, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(2))
+
+	// 338314 is counter 4
+	validated, err := hotp.ValidateAt(338314, 4)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the same request processed again gets the same answer
+	validated, err = hotp.ValidateAt(338314, 4)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// strictly at the counter, the window isn't used
+	validated, err = hotp.ValidateAt(338314, 3)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	assert.Equal(t, uint64(3), hotp.GetCounter())
+	assert.Equal(t, 0, hotp.GetFailures())
+
+	_, hasValidated := hotp.GetLastValidatedCounter()
+	assert.False(t, hasValidated)
+}
+
+func TestValidateFlexibleDigits(t *testing.T) {
+	// 84755224 is counter 0 at 8 digits, 755224 the same truncated value at 6
+	hotp := CreateHotp(secret, 0, 8, "")
+
+	validated, err := hotp.ValidateString("755224")
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = hotp.ValidateFlexibleDigits("755224")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+	assert.Equal(t, 8, hotp.GetDigits())
+
+	// the configured digits still work
+	validated, err = hotp.ValidateFlexibleDigits("94287082")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the window is used for the observed length as well, 969429 is counter 3
+	assert.Nil(t, hotp.SetLookAheadWindow(1))
+	validated, err = hotp.ValidateFlexibleDigits("969429")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(4), hotp.GetCounter())
+
+	// wrong at every length, and lengths that can't be a code
+	for _, code := range []string{"111111", "", "1234567890"} {
+		validated, err = hotp.ValidateFlexibleDigits(code)
+		assert.Nil(t, err, code)
+		assert.False(t, validated, code)
+	}
+	assert.Equal(t, 3, hotp.GetFailures())
+}
+
+func TestValidateFlexibleDigitsShortCodes(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 8, "")
+
+	// the 1 to 5 digit versions of counter 0 are all rejected, shorter than rfc4226 allows
+	for _, code := range []string{"4", "24", "224", "5224", "55224"} {
+		validated, err := hotp.ValidateFlexibleDigits(code)
+		assert.Nil(t, err, code)
+		assert.False(t, validated, code)
+	}
+
+	for digit := range 10 {
+		validated, err := hotp.ValidateFlexibleDigits(string(rune('0' + digit)))
+		assert.Nil(t, err)
+		assert.False(t, validated)
+	}
+
+	// a code longer than the object's digits isn't retried either, 4755224 is 7 digits for a 6 digit object
+	short := CreateHotp(secret, 0, 6, "")
+	validated, err := short.ValidateFlexibleDigits("4755224")
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = hotp.ValidateFlexibleDigits("4755224")
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
+
+func TestEqual(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice")
+	assert.True(t, hotp.Equal(hotp))
+	assert.True(t, hotp.Equal(hotp.Clone()))
+	assert.True(t, hotp.Equal(CreateHotp(secret, 5, 6, "alice")))
+
+	// the hasher differs as a function value, but the algorithm is the same
+	other := CreateHotp(secret, 5, 6, "alice")
+	other.hasher = func() hash.Hash { return sha1.New() }
+	assert.True(t, hotp.Equal(other))
+
+	// a round trip through the snapshot keeps the configuration
+	restored, err := RestoreHotp(hotp.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, hotp.Equal(*restored))
+
+	differing := map[string]func(*Hotp){
+		"secret":    func(h *Hotp) { h.RotateSecret("09876543210987654321"); h.SetCounter(5) },
+		"counter":   func(h *Hotp) { h.IncrementCounter() },
+		"digits":    func(h *Hotp) { h.digits = 8 },
+		"algorithm": func(h *Hotp) { assert.Nil(t, h.SetHashFunc(SHA256)) },
+		"lookAhead": func(h *Hotp) { assert.Nil(t, h.SetLookAheadWindow(2)) },
+		"label":     func(h *Hotp) { h.SetLabel("bob") },
+		"issuer":    func(h *Hotp) { h.SetIssuer("Example") },
+		"offset":    func(h *Hotp) { assert.Nil(t, h.SetTruncationOffset(0)) },
+	}
+
+	for name, change := range differing {
+		changed := hotp.Clone()
+		change(&changed)
+		assert.False(t, hotp.Equal(changed), name)
+		assert.False(t, changed.Equal(hotp), name)
+	}
+}
+
+func TestNext(t *testing.T) {
+	expectedCodes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	hotp := CreateHotp(secret, 0, 6, "")
+	for _, expected := range expectedCodes {
+		code, err := hotp.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	assert.Equal(t, uint64(10), hotp.GetCounter())
+
+	hotp.SetCounter(math.MaxUint64)
+	_, err := hotp.Next()
+	assert.ErrorIs(t, err, ErrCounterExhausted)
+	assert.Equal(t, uint64(math.MaxUint64), hotp.GetCounter())
+}
+
+func TestKeyedMacInvalidated(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	validated, err := hotp.ValidateAt(755224, 0)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the cached hmac is keyed with the old secret and algorithm, it must not be reused
+	hotp.RotateSecret("09876543210987654321")
+	expected, err := CalculateCode("09876543210987654321", 0, 6, sha1.New)
+	assert.Nil(t, err)
+	code, err := hotp.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	assert.Nil(t, hotp.SetHashFunc(SHA256))
+	expected, err = CalculateCode("09876543210987654321", 1, 6, sha256.New)
+	assert.Nil(t, err)
+	code, err = hotp.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	// a clone keys its own
+	clone := hotp.Clone()
+	assert.Nil(t, clone.mac.hash)
+
+	// a plain copy shares the cache, it is keyed again when the copy's algorithm differs
+	copied := hotp
+	assert.Nil(t, copied.SetHashFunc(SHA1))
+	expected, err = CalculateCode("09876543210987654321", 2, 6, sha1.New)
+	assert.Nil(t, err)
+	code, err = copied.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	expected, err = CalculateCode("09876543210987654321", 2, 6, sha256.New)
+	assert.Nil(t, err)
+	code, err = hotp.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+}
+
+// validates with the hmac keyed once per object
+func BenchmarkValidateKeyedMac(b *testing.B) {
+	hotp := CreateHotp(secret, 0, 6, "")
+
+	for i := range uint64(b.N) {
+		_, _ = hotp.ValidateAt(111111, i)
+	}
+}
+
+// keys a new hmac for every code
+func BenchmarkValidateStateless(b *testing.B) {
+	for i := range uint64(b.N) {
+		_, _ = Validate(secret, i, 6, 111111, sha1.New)
+	}
+}
+
+func TestTwoStepResyncCounterMoved(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+	hotp.EnableTwoStepResync(true)
+
+	_, err := hotp.Validate(359152) // counter 2
+	assert.ErrorIs(t, err, ErrResyncPending)
+
+	// setting the counter drops the pending resync, so the code after it doesn't pull the counter back
+	hotp.SetCounter(10)
+	validated, err := hotp.Validate(969429) // counter 3
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(10), hotp.GetCounter())
+
+	// the same once the counter was incremented past the pending one
+	hotp.SetCounter(0)
+	_, err = hotp.Validate(359152) // counter 2
+	assert.ErrorIs(t, err, ErrResyncPending)
+
+	for range 5 {
+		hotp.IncrementCounter()
+	}
+
+	validated, err = hotp.Validate(969429) // counter 3
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+}
+
+func TestTwoStepResync(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(5))
+	hotp.EnableTwoStepResync(true)
+
+	// exact matches are accepted straight away, 755224 is counter 0
+	validated, err := hotp.Validate(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// 254676 is counter 5, ahead of 1. The counter doesn't move until the next code
+	validated, err = hotp.Validate(254676)
+	assert.ErrorIs(t, err, ErrResyncPending)
+	assert.False(t, validated)
+	assert.Equal(t, uint64(1), hotp.GetCounter())
+
+	// 287922 is counter 6, right after the first code
+	validated, delta, err := hotp.ValidateWithResync(287922)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, int64(5), delta)
+	assert.Equal(t, uint64(7), hotp.GetCounter())
+
+	// a second code that isn't the next one starts over
+	hotp.SetCounter(0)
+	_, err = hotp.Validate(359152) // counter 2
+	assert.ErrorIs(t, err, ErrResyncPending)
+
+	_, err = hotp.Validate(338314) // counter 4, not 3
+	assert.ErrorIs(t, err, ErrResyncPending)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	validated, err = hotp.Validate(254676) // counter 5, right after 4
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(6), hotp.GetCounter())
+
+	// the code after the first one can be just outside of the window
+	hotp.SetCounter(0)
+	_, err = hotp.Validate(254676) // counter 5
+	assert.ErrorIs(t, err, ErrResyncPending)
+
+	validated, err = hotp.Validate(287922) // counter 6
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(7), hotp.GetCounter())
+
+	// a wrong code in between drops the pending resync
+	hotp.SetCounter(0)
+	_, err = hotp.Validate(338314)
+	assert.ErrorIs(t, err, ErrResyncPending)
+
+	validated, err = hotp.Validate(111111)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	_, err = hotp.Validate(254676)
+	assert.ErrorIs(t, err, ErrResyncPending)
+	assert.Equal(t, uint64(0), hotp.GetCounter())
+
+	// off again, a single code ahead is enough
+	hotp.EnableTwoStepResync(false)
+	validated, err = hotp.Validate(254676)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(6), hotp.GetCounter())
+}
+
+func TestSupportedHashFuncs(t *testing.T) {
+	registerTestHashFunc(t, HashFunc("sha224-supported"), sha256.New224)
+
+	supported := SupportedHashFuncs()
+	assert.Equal(t, []HashFunc{SHA1, SHA256, SHA512}, supported[:3])
+	assert.Contains(t, supported, HashFunc("sha224-supported"))
+
+	for _, hashFunc := range supported {
+		hotp := CreateHotp(secret, 0, 6, "")
+		assert.Nil(t, hotp.SetHashFunc(hashFunc), hashFunc)
+	}
+
+	SetFIPSMode(true)
+	defer SetFIPSMode(false)
+
+	assert.NotContains(t, SupportedHashFuncs(), SHA1)
+	assert.Contains(t, SupportedHashFuncs(), SHA256)
+}
+
+func TestCalculateUsing(t *testing.T) {
+	hotp := CreateHotp(secret, 1, 6, "")
+
+	sha1Code, err := hotp.CalculateUsing(SHA1)
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", sha1Code)
+
+	sha256Code, err := hotp.CalculateUsing(SHA256)
+	assert.Nil(t, err)
+	assert.NotEqual(t, sha1Code, sha256Code)
+
+	expected, err := CalculateCode(secret, 1, 6, sha256.New)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, sha256Code)
+
+	// the configured algorithm is untouched
+	assert.Equal(t, SHA1, hotp.GetHashFunc())
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, sha1Code, code)
+
+	_, err = hotp.CalculateUsing(HashFunc("md5"))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestValidateWithWindow(t *testing.T) {
+	// 338314 is counter 4
+	stored := CreateHotp(secret, 2, 6, "")
+	assert.Nil(t, stored.SetLookAheadWindow(2))
+
+	windowed := CreateHotp(secret, 2, 6, "")
+
+	validated, err := stored.Validate(338314)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	validated, err = windowed.ValidateWithWindow(338314, 2)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// both advanced past counter 4, and the stored window is still 0
+	assert.Equal(t, stored.GetCounter(), windowed.GetCounter())
+	assert.Equal(t, 0, windowed.GetLookAheadWindow())
+
+	// 162583 is counter 7, two ahead of 5 but not one
+	validated, err = windowed.ValidateWithWindow(162583, 1)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = windowed.Validate(162583)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	// the look behind window set on the object isn't used either, 254676 is counter 5
+	assert.Nil(t, windowed.SetLookBehindWindow(1))
+	windowed.SetCounter(6)
+	validated, err = windowed.ValidateWithWindow(254676, 0)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	_, err = windowed.ValidateWithWindow(338314, maxLookAheadSize+1)
+	assert.ErrorIs(t, err, ErrWindowTooLarge)
+
+	_, err = windowed.ValidateWithWindow(338314, -1)
+	assert.ErrorIs(t, err, ErrNegativeWindow)
+}
+
+func TestRecommendedSecretLength(t *testing.T) {
+	assert.Equal(t, 20, RecommendedSecretLength(SHA1))
+	assert.Equal(t, 32, RecommendedSecretLength(SHA256))
+	assert.Equal(t, 64, RecommendedSecretLength(SHA512))
+	assert.Equal(t, 32, RecommendedSecretLength(HashFunc("SHA-256")))
+	assert.Equal(t, DefaultSecretLength, RecommendedSecretLength(HashFunc("unknown")))
+
+	// md5 is only 16 bytes
+	registerTestHashFunc(t, HashFunc("md5-length"), md5.New)
+	assert.Equal(t, minSecretLength, RecommendedSecretLength(HashFunc("md5-length")))
+
+	for _, algorithm := range []HashFunc{SHA1, SHA256, SHA512} {
+		generated, err := GenerateSecretFor(algorithm)
+		assert.Nil(t, err)
+		assert.Len(t, generated, RecommendedSecretLength(algorithm))
+	}
+}
+
+func TestZeroize(t *testing.T) {
+	hotp := CreateHotpBytes([]byte(secret), 0, 6)
+	_, err := hotp.ValidateAt(755224, 0)
+	assert.Nil(t, err)
+
+	buffer := hotp.secret
+	hotp.Zeroize()
+
+	assert.Equal(t, make([]byte, len(secret)), buffer)
+	assert.False(t, hotp.HasSecret())
+
+	_, err = hotp.Calculate()
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	_, err = hotp.CalculateInt()
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	_, err = hotp.Validate(755224)
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	_, err = hotp.Next()
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	// still unusable after a clone
+	clone := hotp.Clone()
+	_, err = clone.Calculate()
+	assert.ErrorIs(t, err, ErrZeroized)
+}
+
+func TestZeroizeCopies(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "alice")
+
+	// the keyed mac is cached before the copy is made, so the copy has one too
+	validated, err := hotp.Validate(755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	copied := hotp
+	clone := hotp.Clone()
+	hotp.Zeroize()
+
+	// the copy shares the cached hmac, so it no longer holds the key through it either
+	assert.Nil(t, copied.mac.hash)
+	assert.Nil(t, copied.mac.secret)
+
+	// a plain copy shares the wiped buffer, so it must not compute codes from the zeros
+	_, err = copied.Calculate()
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	_, err = copied.Validate(287082)
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	_, err = copied.EnrollmentBundle("alice")
+	assert.ErrorIs(t, err, ErrZeroized)
+
+	assert.False(t, copied.HasSecret())
+	assert.Empty(t, copied.Snapshot().Secret)
+	assert.NotContains(t, copied.GenerateOtpAuth(), "secret=AAAA")
+
+	// a clone has its own secret
+	code, err := clone.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "287082", code)
+}
+
+func TestValidateSecretBytes(t *testing.T) {
+	assert.Nil(t, ValidateSecretBytes([]byte(secret)))
+	assert.Nil(t, ValidateSecretBytes(make([]byte, minSecretLength)))
+	assert.ErrorIs(t, ValidateSecretBytes(nil), ErrEmptySecret)
+	assert.ErrorIs(t, ValidateSecretBytes([]byte("short")), ErrSecretTooShort)
+}
+
+func TestNonASCIISecrets(t *testing.T) {
+	// multi byte characters and bytes that aren't valid UTF-8 at all
+	secrets := [][]byte{
+		[]byte("ünïcödé-sécret-ñ"),
+		{0xff, 0xfe, 0xfd, 0x80, 0x81, 0xc0, 0xc1, 0xf5, 0x00, 0x01, 0xed, 0xa0, 0x80, 0xef, 0xbf, 0xbd},
+	}
+
+	for _, raw := range secrets {
+		assert.Nil(t, ValidateSecretBytes(raw))
+
+		expected, err := CalculateCodeBytes(raw, 0, 6, sha1.New)
+		assert.Nil(t, err)
+
+		// a plain conversion keeps the bytes as they are
+		code, err := CalculateCode(string(raw), 0, 6, sha1.New)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+
+		hotp := CreateHotpBytes(raw, 0, 6)
+		code, err = hotp.Calculate()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	// a JSON round trip replaces the invalid bytes, so the string path hmacs a different key
+	raw := secrets[1]
+	encoded, err := json.Marshal(string(raw))
+	assert.Nil(t, err)
+
+	var decoded string
+	assert.Nil(t, json.Unmarshal(encoded, &decoded))
+	assert.NotEqual(t, raw, []byte(decoded))
+
+	expected, err := CalculateCodeBytes(raw, 0, 6, sha1.New)
+	assert.Nil(t, err)
+	code, err := CalculateCode(decoded, 0, 6, sha1.New)
+	assert.Nil(t, err)
+	assert.NotEqual(t, expected, code)
+}
+
+func TestSetAutoAdvance(t *testing.T) {
+	hotp := CreateHotp(secret, 2, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(3))
+	hotp.SetAutoAdvance(false)
+
+	// code for counter 4
+	for range 2 {
+		validated, delta, err := hotp.ValidateWithDelta(338314)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+		assert.Equal(t, uint64(2), delta)
+		assert.Equal(t, uint64(2), hotp.GetCounter())
+
+		lastCounter, validated := hotp.GetLastValidatedCounter()
+		assert.True(t, validated)
+		assert.Equal(t, uint64(4), lastCounter)
+	}
+
+	// rejected codes still count as failures
+	validated, err := hotp.Validate(0)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, 1, hotp.GetFailures())
+
+	hotp.SetAutoAdvance(true)
+	validated, err = hotp.Validate(338314)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(5), hotp.GetCounter())
+}
+
+func TestValidateMultiAlgo(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.Nil(t, hotp.SetLookAheadWindow(2))
+
+	// the code a client that already moved to SHA256 shows for counter 1
+	migrated := CreateHotp(secret, 1, 6, "")
+	assert.Nil(t, migrated.SetHashFunc(SHA256))
+	sha256Code, err := migrated.CalculateInt()
+	assert.Nil(t, err)
+
+	validated, matched, err := hotp.ValidateMultiAlgo(sha256Code, []HashFunc{SHA1})
+	assert.Nil(t, err)
+	assert.False(t, validated)
+	assert.Equal(t, HashFunc(""), matched)
+
+	algos := []HashFunc{"sha1", "sha256"}
+	validated, matched, err = hotp.ValidateMultiAlgo(sha256Code, algos)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, SHA256, matched)
+	assert.Equal(t, []HashFunc{"sha1", "sha256"}, algos)
+
+	// the counter moved once, and the object still uses SHA1
+	assert.Equal(t, uint64(2), hotp.GetCounter())
+	assert.Equal(t, SHA1, hotp.GetHashFunc())
+
+	// code for counter 2 under SHA1
+	validated, matched, err = hotp.ValidateMultiAlgo(359152, []HashFunc{SHA256, SHA1})
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, SHA1, matched)
+
+	_, _, err = hotp.ValidateMultiAlgo(359152, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	_, _, err = hotp.ValidateMultiAlgo(359152, []HashFunc{"md5"})
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestCalculateWithMeta(t *testing.T) {
+	hotp, err := NewHotp(secret, WithCounter(4), WithHashFunc(SHA256), WithDigits(8))
+	assert.Nil(t, err)
+
+	expected, err := hotp.Calculate()
+	assert.Nil(t, err)
+
+	code, counter, algorithm, err := hotp.CalculateWithMeta()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+	assert.Equal(t, hotp.GetCounter(), counter)
+	assert.Equal(t, hotp.GetHashFunc(), algorithm)
+	assert.Equal(t, uint64(4), counter)
+	assert.Equal(t, SHA256, algorithm)
+	assert.Len(t, code, 8)
+
+	// it reads the object under the lock, so it is safe next to Validate
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for range 50 {
+			_, _ = hotp.Validate(0)
+		}
+	}()
+
+	for range 50 {
+		_, _, _, err = hotp.CalculateWithMeta()
+		assert.Nil(t, err)
+	}
+	wg.Wait()
+
+	hotp.Zeroize()
+	_, _, _, err = hotp.CalculateWithMeta()
+	assert.ErrorIs(t, err, ErrZeroized)
+}
@@ -0,0 +1,31 @@
+package hotp
+
+import (
+	"encoding/json"
+)
+
+/*
+** MarshalJSON serializes the full state of the hotp object as a HotpState, so it can be restored after a restart.
+** NOTE: the output includes the base32 encoded secret, so it has to be stored as securely as the secret itself
+ */
+func (hotp Hotp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hotp.Snapshot())
+}
+
+// UnmarshalJSON restores an hotp object from the output of MarshalJSON, validating it along the way
+func (hotp *Hotp) UnmarshalJSON(data []byte) error {
+	var state HotpState
+
+	err := json.Unmarshal(data, &state)
+	if err != nil {
+		return err
+	}
+
+	restored, err := RestoreHotp(state)
+	if err != nil {
+		return err
+	}
+
+	*hotp = *restored
+	return nil
+}
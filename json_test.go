@@ -0,0 +1,68 @@
+package hotp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	original, err := NewHotp(totpSecrets[SHA512],
+		WithCounter(5),
+		WithDigits(8),
+		WithHashFunc(SHA512),
+		WithLookAhead(3),
+		WithLabel("alice"),
+		WithIssuer("Example"),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, original.SetLookBehindWindow(1))
+
+	data, err := json.Marshal(original)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), totpSecrets[SHA512])
+
+	var restored Hotp
+	assert.Nil(t, json.Unmarshal(data, &restored))
+
+	assert.Equal(t, original.counter, restored.counter)
+	assert.Equal(t, original.digits, restored.digits)
+	assert.Equal(t, original.lookAheadWindow, restored.lookAheadWindow)
+	assert.Equal(t, original.lookBehindWindow, restored.lookBehindWindow)
+	assert.Equal(t, original.hashFunc, restored.hashFunc)
+	assert.Equal(t, original.label, restored.label)
+	assert.Equal(t, original.issuer, restored.issuer)
+
+	expected, err := original.Calculate()
+	assert.Nil(t, err)
+
+	code, err := restored.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+}
+
+func TestJSONUnmarshalInvalid(t *testing.T) {
+	invalid := []string{
+		`{"secret":"not base32!","digits":6,"algorithm":"sha1"}`,
+		`{"secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","digits":12,"algorithm":"sha1"}`,
+		`{"secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","digits":6,"algorithm":"md5"}`,
+		`{"secret":"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ","digits":6,"algorithm":"sha1","lookAheadWindow":100}`,
+	}
+
+	for _, data := range invalid {
+		var hotp Hotp
+		assert.NotNil(t, json.Unmarshal([]byte(data), &hotp), data)
+	}
+}
+
+func TestMarshalZeroValue(t *testing.T) {
+	holder := struct {
+		Token Hotp `json:"token"`
+	}{}
+
+	assert.NotPanics(t, func() {
+		_, err := json.Marshal(holder)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,159 @@
+package hotp
+
+import (
+	"fmt"
+	"hash"
+	"time"
+)
+
+// configures an hotp object created with NewHotp
+type Option func(*Hotp)
+
+func WithDigits(digits int) Option {
+	return func(hotp *Hotp) {
+		hotp.digits = digits
+	}
+}
+
+func WithCounter(counter uint64) Option {
+	return func(hotp *Hotp) {
+		hotp.counter = counter
+	}
+}
+
+// records an option that couldn't be applied, only the first one is kept for NewHotp to report
+func (hotp *Hotp) setOptionErr(err error) {
+	if hotp.optionErr == nil {
+		hotp.optionErr = err
+	}
+}
+
+/*
+** starts the counter at t.Unix() / interval, so it isn't a predictable 0 for every token. It still
+** advances once per code like any hotp counter, see SyncCounterToTime. t can't be before the unix epoch
+ */
+func WithCounterFromTime(t time.Time, interval int) Option {
+	return func(hotp *Hotp) {
+		if interval < 1 {
+			hotp.setOptionErr(fmt.Errorf("%w. Got: %d", ErrInvalidTimeStep, interval))
+			return
+		}
+
+		if t.Unix() < 0 {
+			hotp.setOptionErr(fmt.Errorf("the counter can't start before the unix epoch. Got: %s", t))
+			return
+		}
+
+		hotp.counter = uint64(t.Unix() / int64(interval))
+	}
+}
+
+func WithHashFunc(hashFunc HashFunc) Option {
+	return func(hotp *Hotp) {
+		hotp.hashFunc = hashFunc
+		hotp.customHasher = false
+	}
+}
+
+/*
+** uses factory for the hmac without registering it with RegisterHashFunc. name is what the otpauth
+** uri and GetHashFunc report, so it should be a name the authenticator app knows. NewHotp rejects a
+** nil factory or an empty name. RestoreHotp can only look up registered names
+ */
+func WithHasher(name HashFunc, factory func() hash.Hash) Option {
+	return func(hotp *Hotp) {
+		hotp.hashFunc = name
+		hotp.hasher = factory
+		hotp.customHasher = true
+	}
+}
+
+func WithLookAhead(size int) Option {
+	return func(hotp *Hotp) {
+		hotp.lookAheadWindow = size
+	}
+}
+
+// see SetEncoder
+func WithEncoder(encoder Encoder) Option {
+	return func(hotp *Hotp) {
+		hotp.encoder = encoder
+	}
+}
+
+// encodes codes as length characters of alphabet instead of decimal digits, see AlphabetEncoder
+func WithAlphabet(alphabet string, length int) Option {
+	return WithEncoder(AlphabetEncoder{Alphabet: alphabet, Length: length})
+}
+
+func WithLabel(label string) Option {
+	return func(hotp *Hotp) {
+		hotp.label = label
+	}
+}
+
+// overrides the package issuer set from the ISSUER env for this hotp object
+func WithIssuer(issuer string) Option {
+	return func(hotp *Hotp) {
+		hotp.issuer = issuer
+	}
+}
+
+/*
+** creates an hotp object with 6 digits, a counter of 0, SHA-1 and a look ahead window of 0,
+** unless overridden by the options. The resulting configuration is validated once all options are applied
+ */
+func NewHotp(secret string, opts ...Option) (*Hotp, error) {
+	hotp := CreateHotp(secret, 0, defaultDigits, "")
+
+	for _, opt := range opts {
+		opt(&hotp)
+	}
+
+	if hotp.optionErr != nil {
+		return nil, hotp.optionErr
+	}
+
+	if len(hotp.secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	err := validateDigits(hotp.digits)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateEncoder(hotp.encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hotp.SetLookAheadWindow(hotp.lookAheadWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	if hotp.customHasher {
+		err = validateCustomHasher(hotp.hashFunc, hotp.hasher)
+	} else {
+		err = hotp.SetHashFunc(hotp.hashFunc)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &hotp, nil
+}
+
+func validateCustomHasher(name HashFunc, factory func() hash.Hash) error {
+	if factory == nil {
+		return fmt.Errorf("hashing function '%s' needs a non nil factory", name)
+	}
+
+	if name == "" {
+		return fmt.Errorf("%w: a custom hasher needs a name for the otpauth uri", ErrUnsupportedHash)
+	}
+
+	return checkFIPS(name)
+}
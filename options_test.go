@@ -0,0 +1,121 @@
+package hotp
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHotpDefaults(t *testing.T) {
+	hotp, err := NewHotp(secret)
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint64(0), hotp.counter)
+	assert.Equal(t, 6, hotp.digits)
+	assert.Equal(t, 0, hotp.lookAheadWindow)
+	assert.Equal(t, SHA1, hotp.hashFunc)
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+}
+
+func TestNewHotpOptions(t *testing.T) {
+	hotp, err := NewHotp(totpSecrets[SHA256],
+		WithDigits(8),
+		WithCounter(1),
+		WithHashFunc(SHA256),
+		WithLookAhead(2),
+		WithLabel("alice"),
+		WithIssuer("Example"),
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint64(1), hotp.counter)
+	assert.Equal(t, 8, hotp.digits)
+	assert.Equal(t, 2, hotp.lookAheadWindow)
+	assert.Equal(t, SHA256, hotp.hashFunc)
+
+	// counter 1 is the same as the rfc6238 vector at 59 seconds
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "46119246", code)
+
+	assert.Contains(t, hotp.GenerateOtpAuth(), "otpauth://hotp/Example:alice?")
+	assert.Contains(t, hotp.GenerateOtpAuth(), "issuer=Example")
+}
+
+func TestNewHotpInvalidOptions(t *testing.T) {
+	_, err := NewHotp(secret, WithDigits(10))
+	assert.NotNil(t, err)
+
+	_, err = NewHotp(secret, WithLookAhead(maxLookAheadSize+1))
+	assert.NotNil(t, err)
+
+	_, err = NewHotp(secret, WithHashFunc(HashFunc("md5")))
+	assert.NotNil(t, err)
+}
+
+func TestNewHotpWithHasher(t *testing.T) {
+	// sha224 isn't built in or registered under this name
+	hotp, err := NewHotp(totpSecrets[SHA256], WithHasher(HashFunc("SHA224"), sha256.New224), WithLabel("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, HashFunc("SHA224"), hotp.GetHashFunc())
+
+	expected, err := CalculateCode(totpSecrets[SHA256], 0, 6, sha256.New224)
+	assert.Nil(t, err)
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	assert.True(t, strings.Contains(hotp.GenerateOtpAuth(), "algorithm=SHA224"))
+
+	_, err = NewHotp(secret, WithHasher(HashFunc("SHA224"), nil))
+	assert.NotNil(t, err)
+
+	_, err = NewHotp(secret, WithHasher("", sha256.New224))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	// the last option wins, a later WithHashFunc goes back to the lookup
+	hotp, err = NewHotp(totpSecrets[SHA256], WithHasher(HashFunc("SHA224"), sha256.New224), WithHashFunc(SHA256))
+	assert.Nil(t, err)
+
+	expected, err = CalculateCode(totpSecrets[SHA256], 0, 6, sha256.New)
+	assert.Nil(t, err)
+
+	code, err = hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+}
+
+func TestWithCounterFromTime(t *testing.T) {
+	enrolled := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// 1709294400 / 3600
+	hotp, err := NewHotp(secret, WithCounterFromTime(enrolled, 3600))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(474804), hotp.GetCounter())
+
+	// it advances per code, not with the clock
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+
+	validated, err := hotp.ValidateString(code)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(474805), hotp.GetCounter())
+
+	_, err = NewHotp(secret, WithCounterFromTime(enrolled, 0))
+	assert.ErrorIs(t, err, ErrInvalidTimeStep)
+
+	_, err = NewHotp(secret, WithCounterFromTime(time.Unix(-1, 0), 30))
+	assert.NotNil(t, err)
+
+	// the first option that fails is the one reported
+	_, err = NewHotp(secret, WithCounterFromTime(enrolled, 0), WithCounterFromTime(time.Unix(-1, 0), 30))
+	assert.ErrorIs(t, err, ErrInvalidTimeStep)
+}
@@ -0,0 +1,18 @@
+package hotp
+
+// implemented by *Hotp and *Totp, for code that generates codes without caring which kind it has
+type OTPGenerator interface {
+	Calculate() (string, error)
+}
+
+// implemented by *Hotp and *Totp, e.g. for middleware that checks a code whichever kind the user enrolled
+type OTPValidator interface {
+	Validate(code int) (bool, error)
+}
+
+var (
+	_ OTPGenerator = (*Hotp)(nil)
+	_ OTPGenerator = (*Totp)(nil)
+	_ OTPValidator = (*Hotp)(nil)
+	_ OTPValidator = (*Totp)(nil)
+)
@@ -0,0 +1,36 @@
+package hotp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTPInterfaces(t *testing.T) {
+	hotp := CreateHotp(secret, 1, 8, "")
+
+	// counter 1 at 59 seconds, so both generate the same code from the same secret
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(59))
+
+	for _, generator := range []OTPGenerator{&hotp, &totp} {
+		code, err := generator.Calculate()
+		assert.Nil(t, err)
+		assert.Equal(t, "94287082", code)
+	}
+
+	for _, validator := range []OTPValidator{&hotp, &totp} {
+		validated, err := validator.Validate(94287082)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+	}
+
+	// the hotp counter moved on, the totp step is still the same
+	validated, err := hotp.Validate(94287082)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = totp.Validate(94287082)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
@@ -0,0 +1,323 @@
+package hotp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDigits = 6
+)
+
+// everything BuildOtpAuthURI needs, for provisioning from a secret without an hotp object
+type OtpAuthParams struct {
+	Issuer  string
+	Account string
+	Secret  []byte
+	// defaults to SHA1 when empty
+	Algorithm HashFunc
+	// defaults to 6 when 0
+	Digits  int
+	Counter uint64
+}
+
+// everything an enrollment page shows: the secret for manual entry, and the uri and its qr code for the app
+type EnrollmentBundle struct {
+	// base32 without padding, see EncodeSecret
+	Secret string
+	URI    string
+	// the uri as a png, see GenerateQRCodePNG
+	QRCode []byte
+}
+
+// the enrollment bundle for account, with the issuer from GetIssuer
+func (hotp Hotp) EnrollmentBundle(account string) (EnrollmentBundle, error) {
+	if hotp.isZeroized() {
+		return EnrollmentBundle{}, ErrZeroized
+	}
+
+	if len(hotp.secret) == 0 {
+		return EnrollmentBundle{}, ErrEmptySecret
+	}
+
+	uri := fmt.Sprintf("otpauth://hotp/%s", hotp.otpAuthParamsFor(hotp.issuerOrDefault(), account, false))
+
+	qrCode, err := qrCodePNG(uri, DefaultQRModuleSize, DefaultQRLevel)
+	if err != nil {
+		return EnrollmentBundle{}, err
+	}
+
+	return EnrollmentBundle{
+		Secret: EncodeSecret(hotp.secret),
+		URI:    uri,
+		QRCode: qrCode,
+	}, nil
+}
+
+// builds an otpauth://hotp/ uri from the params, the secret is base32 encoded
+func BuildOtpAuthURI(params OtpAuthParams) (string, error) {
+	if len(params.Secret) == 0 {
+		return "", ErrEmptySecret
+	}
+
+	digits := params.Digits
+	if digits == 0 {
+		digits = defaultDigits
+	}
+
+	err := validateDigits(digits)
+	if err != nil {
+		return "", err
+	}
+
+	algorithm := SHA1
+	if params.Algorithm != "" {
+		algorithm, err = ParseHashFunc(string(params.Algorithm))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	query := url.Values{}
+	query.Set("secret", EncodeSecret(params.Secret))
+	query.Set("algorithm", string(algorithm))
+	query.Set("counter", strconv.FormatUint(params.Counter, 10))
+	query.Set("digits", strconv.Itoa(digits))
+
+	if params.Issuer != "" {
+		query.Set("issuer", params.Issuer)
+	}
+
+	return fmt.Sprintf("otpauth://hotp/%s?%s", FormatLabel(params.Issuer, params.Account), query.Encode()), nil
+}
+
+// configures ParseOtpAuthURI and ParseTotpAuthURI
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	strictIssuer bool
+}
+
+/*
+** rejects a uri whose label prefix (Issuer:account) and issuer parameter are both set but differ,
+** which usually means it was put together by hand or tampered with. Without it the parameter wins
+ */
+func WithStrictIssuer() ParseOption {
+	return func(options *parseOptions) {
+		options.strictIssuer = true
+	}
+}
+
+// the parts of an otpauth uri shared by the hotp and totp types
+type otpAuthURI struct {
+	kind      string
+	label     string
+	issuer    string
+	secret    string
+	digits    int
+	algorithm string
+	query     url.Values
+}
+
+/*
+** parses an otpauth://hotp/ uri back into an hotp object.
+** The secret is required, the rest fall back to the defaults described in
+** https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+** Parameters outside of that format, e.g. image or lock from some app exports, are dropped.
+** The counter can be decimal or 0x prefixed hex, a hex one is exported as hex again, see SetHexCounter.
+** totp uris are rejected, use ParseTotpAuthURI for those
+ */
+func ParseOtpAuthURI(uri string, opts ...ParseOption) (Hotp, error) {
+	parsed, err := parseOtpAuth(uri, opts)
+	if err != nil {
+		return Hotp{}, err
+	}
+
+	if parsed.kind != "hotp" {
+		return Hotp{}, fmt.Errorf("%w: type '%s' is not supported. Only 'hotp' is, use ParseTotpAuthURI for 'totp'", ErrInvalidURI, parsed.kind)
+	}
+
+	var counter uint64
+	hexCounter := false
+	if rawCounter := parsed.query.Get("counter"); rawCounter != "" {
+		counter, hexCounter, err = parseURICounter(rawCounter)
+		if err != nil {
+			return Hotp{}, err
+		}
+	}
+
+	algorithm := SHA1
+	if parsed.algorithm != "" {
+		algorithm = HashFunc(parsed.algorithm)
+	}
+
+	hotp, err := createHotpChecked(parsed.secret, counter, parsed.digits, parsed.label, algorithm)
+	if err != nil {
+		return Hotp{}, err
+	}
+
+	hotp.SetIssuer(parsed.issuer)
+	hotp.SetHexCounter(hexCounter)
+
+	return hotp, nil
+}
+
+// reads a decimal or 0x prefixed hex counter, reporting which one it was
+func parseURICounter(rawCounter string) (uint64, bool, error) {
+	digits, hex := strings.CutPrefix(strings.ToLower(rawCounter), "0x")
+
+	base := 10
+	if hex {
+		base = 16
+	}
+
+	counter, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: counter '%s' is not a decimal or 0x prefixed hex counter", ErrInvalidURI, rawCounter)
+	}
+
+	return counter, hex, nil
+}
+
+// the counter parameter, zero padded to whole bytes when it is hex
+func formatURICounter(counter uint64, hex bool) string {
+	if !hex {
+		return strconv.FormatUint(counter, 10)
+	}
+
+	encoded := strconv.FormatUint(counter, 16)
+	if len(encoded)%2 == 1 {
+		encoded = "0" + encoded
+	}
+
+	return "0x" + encoded
+}
+
+// parses an otpauth://totp/ uri back into a totp object. The period defaults to 30 seconds when absent
+func ParseTotpAuthURI(uri string, opts ...ParseOption) (Totp, error) {
+	parsed, err := parseOtpAuth(uri, opts)
+	if err != nil {
+		return Totp{}, err
+	}
+
+	if parsed.kind != "totp" {
+		return Totp{}, fmt.Errorf("%w: type '%s' is not supported. Only 'totp' is, use ParseOtpAuthURI for 'hotp'", ErrInvalidURI, parsed.kind)
+	}
+
+	// a secret of only padding decodes to nothing, ParseOtpAuthURI gets the same from createHotpChecked
+	if parsed.secret == "" {
+		return Totp{}, ErrEmptySecret
+	}
+
+	err = validateDigits(parsed.digits)
+	if err != nil {
+		return Totp{}, err
+	}
+
+	totp := CreateTotp(parsed.secret, parsed.digits)
+	totp.SetLabel(parsed.label)
+	totp.SetIssuer(parsed.issuer)
+
+	if rawPeriod := parsed.query.Get("period"); rawPeriod != "" {
+		period, err := strconv.Atoi(rawPeriod)
+		if err != nil {
+			return Totp{}, fmt.Errorf("%w: period '%s' is not a number", ErrInvalidTimeStep, rawPeriod)
+		}
+
+		err = totp.SetTimeStep(period)
+		if err != nil {
+			return Totp{}, err
+		}
+	}
+
+	algorithm := SHA1
+	if parsed.algorithm != "" {
+		algorithm = HashFunc(parsed.algorithm)
+	}
+
+	err = totp.SetHashFunc(algorithm)
+	if err != nil {
+		return Totp{}, err
+	}
+
+	return totp, nil
+}
+
+// parses the parts both types have in common, the type specific parameters are left in query
+func parseOtpAuth(uri string, opts []ParseOption) (otpAuthURI, error) {
+	var options parseOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return otpAuthURI{}, err
+	}
+
+	if parsed.Scheme != "otpauth" {
+		return otpAuthURI{}, fmt.Errorf("%w: scheme has to be 'otpauth'. Got: '%s'", ErrInvalidURI, parsed.Scheme)
+	}
+
+	if parsed.Host != "hotp" && parsed.Host != "totp" {
+		return otpAuthURI{}, fmt.Errorf("%w: type '%s' is not supported", ErrInvalidURI, parsed.Host)
+	}
+
+	// the label can be prefixed with the issuer, i.e. Issuer:account. Split before unescaping
+	// so an escaped colon inside the issuer isn't mistaken for the separator
+	label := strings.TrimPrefix(parsed.EscapedPath(), "/")
+	labelIssuer := ""
+	if prefix, account, found := strings.Cut(label, ":"); found {
+		labelIssuer, err = url.PathUnescape(prefix)
+		if err != nil {
+			return otpAuthURI{}, fmt.Errorf("%w: label is not valid: %v", ErrInvalidURI, err)
+		}
+
+		label = account
+	}
+
+	label, err = url.PathUnescape(label)
+	if err != nil {
+		return otpAuthURI{}, fmt.Errorf("%w: label is not valid: %v", ErrInvalidURI, err)
+	}
+
+	query := parsed.Query()
+
+	// the issuer parameter is preferred, the label prefix is only there for older apps
+	uriIssuer := query.Get("issuer")
+	if uriIssuer == "" {
+		uriIssuer = labelIssuer
+	} else if options.strictIssuer && labelIssuer != "" && labelIssuer != uriIssuer {
+		return otpAuthURI{}, fmt.Errorf("%w: the label has '%s' and the parameter '%s'", ErrIssuerMismatch, labelIssuer, uriIssuer)
+	}
+
+	encodedSecret := query.Get("secret")
+	if encodedSecret == "" {
+		return otpAuthURI{}, fmt.Errorf("%w: missing the secret parameter", ErrInvalidURI)
+	}
+
+	secret, err := DecodeSecret(strings.ToUpper(encodedSecret))
+	if err != nil {
+		return otpAuthURI{}, fmt.Errorf("%w: secret is not valid base32: %v", ErrInvalidSecret, err)
+	}
+
+	digits := defaultDigits
+	if rawDigits := query.Get("digits"); rawDigits != "" {
+		digits, err = strconv.Atoi(rawDigits)
+		if err != nil {
+			return otpAuthURI{}, fmt.Errorf("%w: digits '%s' is not a number", ErrInvalidDigits, rawDigits)
+		}
+	}
+
+	return otpAuthURI{
+		kind:      parsed.Host,
+		label:     strings.TrimSpace(label),
+		issuer:    uriIssuer,
+		secret:    secret,
+		digits:    digits,
+		algorithm: query.Get("algorithm"),
+		query:     query,
+	}, nil
+}
@@ -0,0 +1,483 @@
+package hotp
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOtpAuthURIRoundTrip(t *testing.T) {
+	original := CreateHotp(secret, 7, 6, "alice")
+	assert.Nil(t, original.SetHashFunc(SHA256))
+
+	parsed, err := ParseOtpAuthURI(original.GenerateOtpAuth())
+	assert.Nil(t, err)
+
+	assert.Equal(t, original.secret, parsed.secret)
+	assert.Equal(t, original.counter, parsed.counter)
+	assert.Equal(t, original.digits, parsed.digits)
+	assert.Equal(t, original.hashFunc, parsed.hashFunc)
+	assert.Equal(t, "alice", parsed.label)
+
+	expected, err := original.Calculate()
+	assert.Nil(t, err)
+
+	code, err := parsed.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+}
+
+func TestParseOtpAuthURI(t *testing.T) {
+	uri := "otpauth://hotp/Example:alice@example.com?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq&algorithm=sha1&digits=8&counter=4&issuer=Example"
+
+	hotp, err := ParseOtpAuthURI(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@example.com", hotp.label)
+
+	code, err := hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "40338314", code)
+
+	// digits and counter fall back to their defaults
+	hotp, err = ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.Nil(t, err)
+
+	code, err = hotp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "755224", code)
+}
+
+func TestParseOtpAuthURIErrors(t *testing.T) {
+	invalid := map[string]string{
+		"wrong scheme":      "https://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"totp":              "otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"missing secret":    "otpauth://hotp/alice?counter=0",
+		"invalid secret":    "otpauth://hotp/alice?secret=not-base32!",
+		"unknown algorithm": "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=md5",
+		"invalid digits":    "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=12",
+		"invalid counter":   "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=-1",
+		"invalid hex":       "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0xzz",
+		"empty hex":         "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0x",
+	}
+
+	for name, uri := range invalid {
+		_, err := ParseOtpAuthURI(uri)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestGenerateOtpAuthEscaping(t *testing.T) {
+	defer func(original string) {
+		issuer = original
+	}(issuer)
+
+	for _, testIssuer := range []string{"Example Corp", "a&b", "a:b?c", "Exämple"} {
+		issuer = testIssuer
+		hotp := CreateHotp(secret, 0, 6, "alice smith@example.com")
+
+		parsed, err := url.Parse(hotp.GenerateOtpAuth())
+		assert.Nil(t, err, testIssuer)
+
+		assert.Equal(t, "/"+testIssuer+":alice smith@example.com", parsed.Path, testIssuer)
+		assert.Equal(t, testIssuer, parsed.Query().Get("issuer"), testIssuer)
+		assert.Equal(t, EncodeSecret([]byte(secret)), parsed.Query().Get("secret"), testIssuer)
+
+		roundTripped, err := ParseOtpAuthURI(hotp.GenerateOtpAuth())
+		assert.Nil(t, err, testIssuer)
+		assert.Equal(t, "alice smith@example.com", roundTripped.label, testIssuer)
+	}
+}
+
+func TestGenerateOtpAuthLabel(t *testing.T) {
+	defer func(original string) {
+		issuer = original
+	}(issuer)
+
+	issuer = "Example"
+
+	hotp := CreateHotp(secret, 0, 6, "")
+	assert.True(t, strings.HasPrefix(hotp.GenerateOtpAuth(), "otpauth://hotp/Example?"))
+
+	hotp.SetLabel("alice@example.com")
+	assert.Equal(t, "alice@example.com", hotp.GetLabel())
+	assert.True(t, strings.HasPrefix(hotp.GenerateOtpAuth(), "otpauth://hotp/Example:alice@example.com?"))
+	assert.Contains(t, hotp.GenerateOtpAuth(), "issuer=Example")
+}
+
+func TestGenerateOtpAuthDigits(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 8, "alice")
+	assert.Contains(t, hotp.GenerateOtpAuth(), "digits=8")
+
+	parsed, err := ParseOtpAuthURI(hotp.GenerateOtpAuth())
+	assert.Nil(t, err)
+	assert.Equal(t, 8, parsed.digits)
+}
+
+func TestInstanceIssuer(t *testing.T) {
+	defer func(original string) {
+		issuer = original
+	}(issuer)
+
+	issuer = "Default"
+
+	hotp := CreateHotp(secret, 0, 6, "alice")
+	assert.Equal(t, "Default", hotp.GetIssuer())
+	assert.Contains(t, hotp.GenerateOtpAuth(), "otpauth://hotp/Default:alice?")
+
+	hotp.SetIssuer("Tenant A")
+	other := CreateHotp(secret, 0, 6, "bob")
+	other.SetIssuer("Tenant B")
+
+	assert.Equal(t, "Tenant A", hotp.GetIssuer())
+	assert.Contains(t, hotp.GenerateOtpAuth(), "issuer=Tenant+A")
+	assert.Equal(t, "Tenant B", other.GetIssuer())
+	assert.Contains(t, other.GenerateOtpAuth(), "issuer=Tenant+B")
+
+	// clearing the issuer goes back to the package default
+	hotp.SetIssuer("")
+	assert.Equal(t, "Default", hotp.GetIssuer())
+}
+
+func TestParseOtpAuthURIIssuer(t *testing.T) {
+	hotp, err := ParseOtpAuthURI("otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example")
+	assert.Nil(t, err)
+	assert.Equal(t, "Example", hotp.GetIssuer())
+
+	// older uris only have the issuer as the label prefix
+	hotp, err = ParseOtpAuthURI("otpauth://hotp/Legacy%20Corp:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.Nil(t, err)
+	assert.Equal(t, "Legacy Corp", hotp.GetIssuer())
+}
+
+func TestParseOtpAuthURIStrictIssuer(t *testing.T) {
+	const mismatched = "otpauth://hotp/Other:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example"
+
+	// tolerant by default, the parameter wins
+	hotp, err := ParseOtpAuthURI(mismatched)
+	assert.Nil(t, err)
+	assert.Equal(t, "Example", hotp.GetIssuer())
+	assert.Equal(t, "alice", hotp.GetLabel())
+
+	_, err = ParseOtpAuthURI(mismatched, WithStrictIssuer())
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+
+	_, err = ParseTotpAuthURI(strings.Replace(mismatched, "hotp", "totp", 1), WithStrictIssuer())
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+
+	// matching, or only one of them set, is fine
+	for _, uri := range []string{
+		"otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example",
+		"otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example",
+		"otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+	} {
+		hotp, err := ParseOtpAuthURI(uri, WithStrictIssuer())
+		assert.Nil(t, err, uri)
+		assert.Equal(t, "Example", hotp.GetIssuer(), uri)
+		assert.Equal(t, "alice", hotp.GetLabel(), uri)
+	}
+}
+
+func TestOmitIssuerLabel(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "alice")
+	hotp.SetIssuer("Example")
+
+	assert.True(t, strings.HasPrefix(hotp.GenerateOtpAuth(), "otpauth://hotp/Example:alice?"))
+	assert.Contains(t, hotp.GenerateOtpAuth(), "issuer=Example")
+
+	hotp.SetOmitIssuerLabel(true)
+	assert.True(t, strings.HasPrefix(hotp.GenerateOtpAuth(), "otpauth://hotp/alice?"))
+	assert.Contains(t, hotp.GenerateOtpAuth(), "issuer=Example")
+
+	// both forms parse back to the same issuer and account
+	parsed, err := ParseOtpAuthURI(hotp.GenerateOtpAuth())
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", parsed.GetLabel())
+	assert.Equal(t, "Example", parsed.GetIssuer())
+}
+
+func TestParseOtpAuthURIAlgorithmCase(t *testing.T) {
+	for _, algorithm := range []string{"SHA1", "sha1", "Sha1", "SHA256", "SHA512", "SHA-512"} {
+		hotp, err := ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=" + algorithm)
+		assert.Nil(t, err, algorithm)
+
+		expected, err := ParseHashFunc(algorithm)
+		assert.Nil(t, err, algorithm)
+		assert.Equal(t, expected, hotp.GetHashFunc(), algorithm)
+	}
+}
+
+func TestTotpOtpAuthPeriodRoundTrip(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	assert.Nil(t, totp.SetTimeStep(60))
+	totp.SetLabel("alice")
+	totp.SetIssuer("Example")
+
+	uri := totp.GenerateOtpAuth()
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Example:alice?"))
+	assert.Contains(t, uri, "period=60")
+
+	parsed, err := ParseTotpAuthURI(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, 60, parsed.GetTimeStep())
+	assert.Equal(t, "alice", parsed.GetLabel())
+	assert.Equal(t, "Example", parsed.GetIssuer())
+
+	// with a 60 second step, 119 seconds lands on the same counter as 59 seconds with 30
+	parsed.SetClock(fixedClock(119))
+	code, err := parsed.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "94287082", code)
+	assert.Equal(t, uri, parsed.GenerateOtpAuth())
+}
+
+func TestParseTotpAuthURIDefaults(t *testing.T) {
+	totp, err := ParseTotpAuthURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTimeStep, totp.GetTimeStep())
+
+	_, err = ParseTotpAuthURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=0")
+	assert.True(t, errors.Is(err, ErrInvalidTimeStep))
+
+	_, err = ParseTotpAuthURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=abc")
+	assert.True(t, errors.Is(err, ErrInvalidTimeStep))
+
+	_, err = ParseTotpAuthURI("otpauth://totp/alice?secret=====")
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+
+	_, err = ParseOtpAuthURI("otpauth://hotp/alice?secret=====")
+	assert.True(t, errors.Is(err, ErrEmptySecret))
+}
+
+func TestParseOtpAuthRouting(t *testing.T) {
+	hotpURI := "otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3"
+	totpURI := "otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=60"
+
+	_, err := ParseOtpAuthURI(totpURI)
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+
+	_, err = ParseTotpAuthURI(hotpURI)
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+
+	_, err = ParseTotpAuthURI("otpauth://motp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+}
+
+func TestBuildOtpAuthURI(t *testing.T) {
+	uri, err := BuildOtpAuthURI(OtpAuthParams{
+		Issuer:    "Example & Co",
+		Account:   "alice@example.com",
+		Secret:    []byte(secret),
+		Algorithm: SHA256,
+		Digits:    8,
+		Counter:   42,
+	})
+	assert.Nil(t, err)
+
+	parsed, err := url.Parse(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "hotp", parsed.Host)
+	assert.Equal(t, "/Example & Co:alice@example.com", parsed.Path)
+
+	query := parsed.Query()
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", query.Get("secret"))
+	assert.Equal(t, "sha256", query.Get("algorithm"))
+	assert.Equal(t, "42", query.Get("counter"))
+	assert.Equal(t, "8", query.Get("digits"))
+	assert.Equal(t, "Example & Co", query.Get("issuer"))
+
+	hotp, err := ParseOtpAuthURI(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@example.com", hotp.GetLabel())
+	assert.Equal(t, uint64(42), hotp.GetCounter())
+
+	// the defaults, and no issuer at all
+	uri, err = BuildOtpAuthURI(OtpAuthParams{Account: "alice", Secret: []byte(secret)})
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth://hotp/alice?algorithm=sha1&counter=0&digits=6&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", uri)
+
+	_, err = BuildOtpAuthURI(OtpAuthParams{Account: "alice"})
+	assert.ErrorIs(t, err, ErrEmptySecret)
+
+	_, err = BuildOtpAuthURI(OtpAuthParams{Secret: []byte(secret), Digits: 10})
+	assert.ErrorIs(t, err, ErrInvalidDigits)
+
+	_, err = BuildOtpAuthURI(OtpAuthParams{Secret: []byte(secret), Algorithm: HashFunc("md5")})
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestGenerateOtpAuthWithPadding(t *testing.T) {
+	// 16 bytes don't fill the last base32 block, so the padded secret ends in 6 "="
+	key := "1234567890123456"
+	hotp := CreateHotp(key, 0, 6, "alice")
+
+	assert.Equal(t, hotp.GenerateOtpAuth(), hotp.GenerateOtpAuthWithPadding(false))
+
+	secrets := map[bool]string{}
+	for _, pad := range []bool{true, false} {
+		parsed, err := url.Parse(hotp.GenerateOtpAuthWithPadding(pad))
+		assert.Nil(t, err)
+
+		secrets[pad] = parsed.Query().Get("secret")
+
+		decoded, err := DecodeSecretBytes(secrets[pad])
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(key), decoded)
+
+		imported, err := ParseOtpAuthURI(hotp.GenerateOtpAuthWithPadding(pad))
+		assert.Nil(t, err)
+		assert.Equal(t, hotp.GenerateOtpAuth(), imported.GenerateOtpAuth())
+	}
+
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY======", secrets[true])
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY", secrets[false])
+}
+
+func TestGenerateOtpAuthParamsFor(t *testing.T) {
+	hotp := CreateHotp(secret, 3, 6, "ignored")
+	hotp.SetIssuer("Ignored")
+
+	params := hotp.GenerateOtpAuthParamsFor("Example", "alice")
+	assert.Equal(t, "Example:alice?algorithm=sha1&counter=3&digits=6&issuer=Example&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", params)
+
+	parsed, err := ParseOtpAuthURI("otpauth://hotp/" + params)
+	assert.Nil(t, err)
+	assert.Equal(t, "Example", parsed.GetIssuer())
+	assert.Equal(t, "alice", parsed.GetLabel())
+
+	// no issuer at all, not even the package default
+	params = hotp.GenerateOtpAuthParamsFor("", "alice")
+	assert.Equal(t, "alice?algorithm=sha1&counter=3&digits=6&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", params)
+
+	hotp.SetOmitIssuerLabel(true)
+	params = hotp.GenerateOtpAuthParamsFor("Example", "alice")
+	assert.True(t, strings.HasPrefix(params, "alice?"))
+	assert.Contains(t, params, "issuer=Example")
+}
+
+func TestParseOtpAuthURIUnknownParams(t *testing.T) {
+	uri := "otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example&counter=2" +
+		"&image=https%3A%2F%2Fexample.com%2Flogo.png&lock=true&color=ff0000&icon=&digits=6"
+
+	hotp, err := ParseOtpAuthURI(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", hotp.GetLabel())
+	assert.Equal(t, "Example", hotp.GetIssuer())
+	assert.Equal(t, uint64(2), hotp.GetCounter())
+
+	validated, err := hotp.Validate(359152)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// dropped on export
+	assert.NotContains(t, hotp.GenerateOtpAuth(), "image")
+	assert.NotContains(t, hotp.GenerateOtpAuth(), "lock")
+
+	totp, err := ParseTotpAuthURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&image=logo.png&lock=false")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", totp.GetLabel())
+
+	// the required ones are still checked
+	_, err = ParseOtpAuthURI("otpauth://hotp/alice?image=logo.png&lock=true")
+	assert.ErrorIs(t, err, ErrInvalidURI)
+}
+
+func TestFormatLabel(t *testing.T) {
+	expected := []struct {
+		issuer  string
+		account string
+		label   string
+	}{
+		{"Example", "alice", "Example:alice"},
+		{"", "alice", "alice"},
+		{"Example", "", "Example"},
+		{"", "", ""},
+		{"Example Co", "alice@example.com", "Example%20Co:alice@example.com"},
+		{"A:B", "c:d", "A%3AB:c%3Ad"},
+		{"a/b?c#d", "e&f", "a%2Fb%3Fc%23d:e&f"},
+		{"Bücher", "ünïcode", "B%C3%BCcher:%C3%BCn%C3%AFcode"},
+	}
+
+	for _, e := range expected {
+		label := FormatLabel(e.issuer, e.account)
+		assert.Equal(t, e.label, label, "%q %q", e.issuer, e.account)
+
+		// parses back to the same components
+		hotp, err := ParseOtpAuthURI("otpauth://hotp/" + label + "?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=" + url.QueryEscape(e.issuer))
+		assert.Nil(t, err)
+		if e.account != "" || e.issuer == "" {
+			assert.Equal(t, e.account, hotp.GetLabel(), "%q %q", e.issuer, e.account)
+		}
+	}
+
+	// the uris use it as well
+	hotp := CreateHotp(secret, 0, 6, "alice@example.com")
+	hotp.SetIssuer("Example Co")
+	assert.True(t, strings.HasPrefix(hotp.GenerateOtpAuth(), "otpauth://hotp/"+FormatLabel("Example Co", "alice@example.com")+"?"))
+
+	totp := CreateTotp(secret, 6)
+	totp.SetLabel("alice@example.com")
+	totp.SetIssuer("Example Co")
+	assert.True(t, strings.HasPrefix(totp.GenerateOtpAuth(), "otpauth://totp/"+FormatLabel("Example Co", "alice@example.com")+"?"))
+}
+
+func TestEnrollmentBundle(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "")
+	hotp.SetIssuer("ACME")
+
+	bundle, err := hotp.EnrollmentBundle("alice@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", bundle.Secret)
+
+	parsed, err := ParseOtpAuthURI(bundle.URI)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@example.com", parsed.GetLabel())
+	assert.Equal(t, "ACME", parsed.GetIssuer())
+	assert.Equal(t, uint64(5), parsed.GetCounter())
+
+	decoded, err := DecodeSecret(bundle.Secret)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, decoded)
+
+	// the uri carries the same secret, so both sides generate the same codes
+	expected, err := hotp.Calculate()
+	assert.Nil(t, err)
+	code, err := parsed.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, code)
+
+	// the qr code scans to the same uri
+	assert.NotEmpty(t, bundle.QRCode)
+	assert.Equal(t, bundle.URI, decodeQRCodePNG(t, bundle.QRCode))
+
+	hotp.Zeroize()
+	_, err = hotp.EnrollmentBundle("alice@example.com")
+	assert.ErrorIs(t, err, ErrZeroized)
+}
+
+func TestOtpAuthHexCounter(t *testing.T) {
+	for _, rawCounter := range []string{"12", "0x0c", "0X0C"} {
+		hotp, err := ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=" + rawCounter)
+		assert.Nil(t, err, rawCounter)
+		assert.Equal(t, uint64(12), hotp.GetCounter(), rawCounter)
+	}
+
+	// each form is exported the way it was imported
+	for _, rawCounter := range []string{"12", "0x0c", "0x0100"} {
+		uri := "otpauth://hotp/alice?algorithm=sha1&counter=" + rawCounter + "&digits=6&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+		hotp, err := ParseOtpAuthURI(uri)
+		assert.Nil(t, err)
+		assert.Equal(t, uri, "otpauth://hotp/"+hotp.GenerateOtpAuthParamsFor("", "alice"), rawCounter)
+	}
+
+	hotp := CreateHotp(secret, 255, 6, "alice")
+	hotp.SetHexCounter(true)
+	assert.Contains(t, hotp.GenerateOtpAuth(), "counter=0xff")
+
+	_, err := ParseOtpAuthURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0xg1")
+	assert.ErrorIs(t, err, ErrInvalidURI)
+}
@@ -0,0 +1,172 @@
+package hotp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// how much of a qr code can be damaged and still scan, a higher level makes for a denser code
+type QRLevel int
+
+const (
+	// 7% can be lost
+	QRLow QRLevel = iota
+	// 15% can be lost
+	QRMedium
+	// 25% can be lost
+	QRHigh
+	// 30% can be lost
+	QRHighest
+)
+
+const (
+	// the size in pixels of one module (a square of the code) in GenerateQRCodePNG
+	DefaultQRModuleSize = 8
+	DefaultQRLevel      = QRMedium
+)
+
+func (level QRLevel) recoveryLevel() (qrcode.RecoveryLevel, error) {
+	switch level {
+	case QRLow:
+		return qrcode.Low, nil
+	case QRMedium:
+		return qrcode.Medium, nil
+	case QRHigh:
+		return qrcode.High, nil
+	case QRHighest:
+		return qrcode.Highest, nil
+	}
+
+	return 0, fmt.Errorf("%w. Got: %d", ErrInvalidQRLevel, level)
+}
+
+func newQRCode(content string, level QRLevel) (*qrcode.QRCode, error) {
+	recoveryLevel, err := level.recoveryLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	return qrcode.New(content, recoveryLevel)
+}
+
+func qrCodePNG(content string, moduleSize int, level QRLevel) ([]byte, error) {
+	if moduleSize < 1 {
+		return nil, fmt.Errorf("%w. Got: %d", ErrInvalidQRSize, moduleSize)
+	}
+
+	code, err := newQRCode(content, level)
+	if err != nil {
+		return nil, err
+	}
+
+	// a negative size is taken as the pixels per module, the image is as large as the code needs
+	return code.PNG(-moduleSize)
+}
+
+// the uri the qr codes render, an error instead of a uri without a secret
+func (hotp Hotp) qrContent() (string, error) {
+	if hotp.isZeroized() {
+		return "", ErrZeroized
+	}
+
+	if len(hotp.secret) == 0 {
+		return "", ErrEmptySecret
+	}
+
+	return hotp.GenerateOtpAuth(), nil
+}
+
+// GenerateOtpAuth as a png qr code for an authenticator app to scan, see GenerateQRCodePNGWith
+func (hotp Hotp) GenerateQRCodePNG() ([]byte, error) {
+	return hotp.GenerateQRCodePNGWith(DefaultQRModuleSize, DefaultQRLevel)
+}
+
+/*
+** same as GenerateQRCodePNG, with moduleSize pixels per module and the given error correction level.
+** The image is sized to fit the code, along with the quiet zone scanners need around it
+ */
+func (hotp Hotp) GenerateQRCodePNGWith(moduleSize int, level QRLevel) ([]byte, error) {
+	content, err := hotp.qrContent()
+	if err != nil {
+		return nil, err
+	}
+
+	return qrCodePNG(content, moduleSize, level)
+}
+
+// GenerateOtpAuth as a qr code drawn with block characters, for showing in a terminal
+func (hotp Hotp) GenerateQRCodeASCII() (string, error) {
+	return hotp.GenerateQRCodeASCIIWith(DefaultQRLevel)
+}
+
+// same as GenerateQRCodeASCII, with the given error correction level
+func (hotp Hotp) GenerateQRCodeASCIIWith(level QRLevel) (string, error) {
+	var ascii strings.Builder
+
+	err := hotp.WriteQRCodeASCIIWith(&ascii, level)
+	if err != nil {
+		return "", err
+	}
+
+	return ascii.String(), nil
+}
+
+// same as GenerateQRCodeASCII, but written to w a line at a time instead of built up as one string
+func (hotp Hotp) WriteQRCodeASCII(w io.Writer) error {
+	return hotp.WriteQRCodeASCIIWith(w, DefaultQRLevel)
+}
+
+// same as WriteQRCodeASCII, with the given error correction level
+func (hotp Hotp) WriteQRCodeASCIIWith(w io.Writer, level QRLevel) error {
+	content, err := hotp.qrContent()
+	if err != nil {
+		return err
+	}
+
+	code, err := newQRCode(content, level)
+	if err != nil {
+		return err
+	}
+
+	return writeQRCodeASCII(w, code.Bitmap())
+}
+
+/*
+** draws two rows of modules per line, so the code isn't stretched by the terminal's tall characters.
+** Light modules are drawn as blocks, for the usual light text on a dark background
+ */
+func writeQRCodeASCII(w io.Writer, bits [][]bool) error {
+	var line strings.Builder
+
+	for y := 0; y < len(bits); y += 2 {
+		line.Reset()
+
+		for x := range bits[y] {
+			// an odd last row is drawn as top halves only, the terminal background showing below them
+			top, bottom := bits[y][x], y+1 == len(bits) || bits[y+1][x]
+
+			switch {
+			case top && bottom:
+				line.WriteString(" ")
+			case top:
+				line.WriteString("▄")
+			case bottom:
+				line.WriteString("▀")
+			default:
+				line.WriteString("█")
+			}
+		}
+
+		line.WriteString("\n")
+
+		_, err := io.WriteString(w, line.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package hotp
+
+import (
+	"bytes"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	gozxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/stretchr/testify/assert"
+)
+
+// reads the text back out of a png qr code, the way a scanner would
+func decodeQRCodePNG(t *testing.T, data []byte) string {
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.Nil(t, err)
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	assert.Nil(t, err)
+
+	result, err := gozxingqr.NewQRCodeReader().Decode(bitmap, nil)
+	assert.Nil(t, err)
+
+	return result.GetText()
+}
+
+func TestGenerateQRCodePNG(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+	hotp.SetIssuer("ACME")
+
+	data, err := hotp.GenerateQRCodePNG()
+	assert.Nil(t, err)
+	assert.Equal(t, hotp.GenerateOtpAuth(), decodeQRCodePNG(t, data))
+
+	for _, level := range []QRLevel{QRLow, QRMedium, QRHigh, QRHighest} {
+		data, err := hotp.GenerateQRCodePNGWith(4, level)
+		assert.Nil(t, err)
+		assert.Equal(t, hotp.GenerateOtpAuth(), decodeQRCodePNG(t, data))
+	}
+}
+
+func TestGenerateQRCodePNGModuleSize(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+
+	small, err := hotp.GenerateQRCodePNGWith(2, QRMedium)
+	assert.Nil(t, err)
+	large, err := hotp.GenerateQRCodePNGWith(4, QRMedium)
+	assert.Nil(t, err)
+
+	smallImg, err := png.Decode(bytes.NewReader(small))
+	assert.Nil(t, err)
+	largeImg, err := png.Decode(bytes.NewReader(large))
+	assert.Nil(t, err)
+	assert.Equal(t, smallImg.Bounds().Dx()*2, largeImg.Bounds().Dx())
+}
+
+func TestGenerateQRCodeErrors(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+
+	_, err := hotp.GenerateQRCodePNGWith(0, QRMedium)
+	assert.ErrorIs(t, err, ErrInvalidQRSize)
+
+	_, err = hotp.GenerateQRCodePNGWith(4, QRLevel(7))
+	assert.ErrorIs(t, err, ErrInvalidQRLevel)
+
+	_, err = hotp.GenerateQRCodeASCIIWith(QRLevel(-1))
+	assert.ErrorIs(t, err, ErrInvalidQRLevel)
+
+	_, err = CreateHotp("", 5, 6, "").GenerateQRCodePNG()
+	assert.ErrorIs(t, err, ErrEmptySecret)
+
+	hotp.Zeroize()
+	_, err = hotp.GenerateQRCodePNG()
+	assert.ErrorIs(t, err, ErrZeroized)
+	_, err = hotp.GenerateQRCodeASCII()
+	assert.ErrorIs(t, err, ErrZeroized)
+}
+
+func TestGenerateQRCodeASCII(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+
+	ascii, err := hotp.GenerateQRCodeASCII()
+	assert.Nil(t, err)
+	assert.True(t, strings.ContainsAny(ascii, "█▀▄"))
+
+	lines := strings.Split(strings.TrimSuffix(ascii, "\n"), "\n")
+	for _, line := range lines {
+		assert.Equal(t, len([]rune(lines[0])), len([]rune(line)))
+	}
+}
+
+func TestWriteQRCodeASCII(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+
+	var buf bytes.Buffer
+	assert.Nil(t, hotp.WriteQRCodeASCII(&buf))
+	assert.True(t, strings.ContainsAny(buf.String(), "█▀▄"))
+
+	ascii, err := hotp.GenerateQRCodeASCII()
+	assert.Nil(t, err)
+	assert.Equal(t, ascii, buf.String())
+
+	// drawn the same as the qr library's own small rendering
+	code, err := newQRCode(hotp.GenerateOtpAuth(), DefaultQRLevel)
+	assert.Nil(t, err)
+	assert.Equal(t, code.ToSmallString(false), buf.String())
+
+	hotp.Zeroize()
+	buf.Reset()
+	assert.ErrorIs(t, hotp.WriteQRCodeASCII(&buf), ErrZeroized)
+	assert.Empty(t, buf.String())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestWriteQRCodeASCIIWriterError(t *testing.T) {
+	hotp := CreateHotp(secret, 5, 6, "alice@example.com")
+	assert.ErrorIs(t, hotp.WriteQRCodeASCII(failingWriter{}), io.ErrClosedPipe)
+}
@@ -0,0 +1,69 @@
+package hotp
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	base32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+)
+
+/*
+** cleans up a base32 secret typed or pasted by a user, e.g. "abcd efgh-ijkl" -> "ABCDEFGHIJKL".
+** Spaces and hyphens are removed, letters are uppercased, and padding is dropped.
+** Returns an error if anything outside the base32 alphabet is left, or the length can't be base32
+ */
+func NormalizeSecret(secret string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(secret))
+
+	normalized = strings.TrimRight(normalized, "=")
+	if normalized == "" {
+		return "", ErrEmptySecret
+	}
+
+	for i, r := range normalized {
+		if !strings.ContainsRune(base32Alphabet, r) {
+			return "", fmt.Errorf("%w: invalid base32 character '%c' at position %d", ErrInvalidSecret, r, i)
+		}
+	}
+
+	// 1, 3 and 6 trailing characters can't come from whole bytes
+	switch len(normalized) % 8 {
+	case 1, 3, 6:
+		return "", fmt.Errorf("%w: length %d is not a valid base32 length", ErrInvalidSecret, len(normalized))
+	}
+
+	return normalized, nil
+}
+
+// reports whether the secret is well formed base32 once normalized, see NormalizeSecret
+func IsValidBase32Secret(secret string) bool {
+	_, err := NormalizeSecret(secret)
+	return err == nil
+}
+
+// splits an encoded secret into groups of groupSize characters for manual entry, e.g. "ABCD EFGH IJKL".
+// NormalizeSecret removes the spaces again. A groupSize below 1 returns the secret as is
+func FormatSecretForDisplay(encoded string, groupSize int) string {
+	if groupSize < 1 {
+		return encoded
+	}
+
+	var display strings.Builder
+	for i, r := range encoded {
+		if i > 0 && i%groupSize == 0 {
+			display.WriteByte(' ')
+		}
+
+		display.WriteRune(r)
+	}
+
+	return display.String()
+}
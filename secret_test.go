@@ -0,0 +1,92 @@
+package hotp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSecret(t *testing.T) {
+	expected := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	inputs := []string{
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"gezdgnbvgy3tqojqgezdgnbvgy3tqojq",
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+		"GEZD-GNBV-GY3T-QOJQ-GEZD-GNBV-GY3T-QOJQ",
+		" GEZDgnbvGY3TQOJQ GEZDGNBVGY3TQOJQ ",
+	}
+
+	for _, input := range inputs {
+		normalized, err := NormalizeSecret(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, normalized, input)
+		assert.True(t, IsValidBase32Secret(input), input)
+
+		decoded, err := DecodeSecret(normalized)
+		assert.Nil(t, err, input)
+		assert.Equal(t, secret, decoded, input)
+	}
+
+	// padding is accepted and dropped
+	normalized, err := NormalizeSecret("MFRGG===")
+	assert.Nil(t, err)
+	assert.Equal(t, "MFRGG", normalized)
+}
+
+func TestNormalizeSecretInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"   ",
+		"GEZDGNBVGY3TQOJ0", // 0 isn't in the alphabet
+		"GEZDGNBVGY3TQOJ1", // neither is 1
+		"GEZDGNBV!Y3TQOJQ",
+		"GEZDGNBVG", // 9 characters can't be whole bytes
+	}
+
+	for _, input := range inputs {
+		_, err := NormalizeSecret(input)
+		assert.NotNil(t, err, input)
+		assert.False(t, IsValidBase32Secret(input), input)
+	}
+}
+
+func TestDecodeSecretStrict(t *testing.T) {
+	for _, encoded := range []string{"MFRGG", "MFRGG===", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"} {
+		strict, err := DecodeSecretStrict(encoded)
+		assert.Nil(t, err, encoded)
+
+		lenient, err := DecodeSecretBytes(encoded)
+		assert.Nil(t, err, encoded)
+		assert.Equal(t, lenient, strict, encoded)
+	}
+
+	// "abc" is MFRGG, the H sets trailing bits that don't belong to any byte. The padding is one short
+	for _, malformed := range []string{"MFRGH", "MFRGG=="} {
+		_, err := DecodeSecretBytes(malformed)
+		assert.Nil(t, err, malformed)
+
+		_, err = DecodeSecretStrict(malformed)
+		assert.ErrorIs(t, err, ErrInvalidSecret, malformed)
+	}
+}
+
+func TestFormatSecretForDisplay(t *testing.T) {
+	encoded := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	expected := map[int]string{
+		3: "GEZ DGN BVG Y3T QOJ QGE ZDG NBV GY3 TQO JQ",
+		4: "GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ",
+	}
+
+	for groupSize, display := range expected {
+		assert.Equal(t, display, FormatSecretForDisplay(encoded, groupSize))
+
+		normalized, err := NormalizeSecret(display)
+		assert.Nil(t, err)
+		assert.Equal(t, encoded, normalized)
+	}
+
+	assert.Equal(t, encoded, FormatSecretForDisplay(encoded, 0))
+	assert.Equal(t, "", FormatSecretForDisplay("", 4))
+}
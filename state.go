@@ -0,0 +1,138 @@
+package hotp
+
+/*
+** HotpState is everything needed to recreate an hotp object, e.g. to persist a token's counter across restarts.
+** NOTE: Secret is the base32 encoded secret, so the state has to be stored as securely as the secret itself
+ */
+type HotpState struct {
+	Secret           string   `json:"secret"`
+	Counter          uint64   `json:"counter"`
+	Digits           int      `json:"digits"`
+	HashFunc         HashFunc `json:"algorithm"`
+	LookAheadWindow  int      `json:"lookAheadWindow"`
+	LookBehindWindow int      `json:"lookBehindWindow"`
+	ResyncWindow     int      `json:"resyncWindow,omitempty"`
+	Label            string   `json:"label"`
+	Issuer           string   `json:"issuer"`
+	OmitIssuerLabel  bool     `json:"omitIssuerLabel,omitempty"`
+	// nil for dynamic truncation, see SetTruncationOffset
+	TruncationOffset *int `json:"truncationOffset,omitempty"`
+	// 0 for the rfc4226 8 bytes, see SetCounterBytes
+	CounterBytes int `json:"counterBytes,omitempty"`
+	MaxFailures  int `json:"maxFailures,omitempty"`
+	Failures     int `json:"failures,omitempty"`
+	// the counter of the last accepted code, nil if no code has been accepted yet
+	LastValidatedCounter *uint64 `json:"lastValidatedCounter,omitempty"`
+	// the counter is written as 0x hex in otpauth uris, see SetHexCounter
+	HexCounter bool `json:"hexCounter,omitempty"`
+	// auto advance is off, see SetAutoAdvance
+	ManualCounter bool `json:"manualCounter,omitempty"`
+	// see EnableTwoStepResync
+	TwoStepResync bool `json:"twoStepResync,omitempty"`
+}
+
+// captures the current state of the hotp object
+func (hotp *Hotp) Snapshot() HotpState {
+	hotp.mutex().Lock()
+	defer hotp.mu.Unlock()
+
+	state := HotpState{
+		Secret:           EncodeSecret(hotp.liveSecret()),
+		Counter:          hotp.counter,
+		Digits:           hotp.digits,
+		HashFunc:         hotp.hashFunc,
+		LookAheadWindow:  hotp.lookAheadWindow,
+		LookBehindWindow: hotp.lookBehindWindow,
+		ResyncWindow:     hotp.resyncWindow,
+		Label:            hotp.label,
+		Issuer:           hotp.issuer,
+		OmitIssuerLabel:  hotp.omitIssuerLabel,
+		CounterBytes:     hotp.counterBytes,
+		MaxFailures:      hotp.maxFailures,
+		Failures:         hotp.failures,
+		HexCounter:       hotp.hexCounter,
+		ManualCounter:    hotp.manualCounter,
+		TwoStepResync:    hotp.twoStepResync,
+	}
+
+	if hotp.fixedOffset {
+		truncationOffset := hotp.truncationOffset
+		state.TruncationOffset = &truncationOffset
+	}
+
+	if hotp.hasValidated {
+		lastValidatedCounter := hotp.lastValidatedCounter
+		state.LastValidatedCounter = &lastValidatedCounter
+	}
+
+	return state
+}
+
+// recreates an hotp object from a snapshot, validating it the same way the constructors do
+func RestoreHotp(state HotpState) (*Hotp, error) {
+	secret, err := DecodeSecret(state.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hotp, err := createHotpValidated(secret, state.Counter, state.Digits, state.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hasher isn't serializable, so it is looked up again from the algorithm. FIPS mode only
+	// keeps SHA1 from new tokens, a token persisted before it was turned on still restores
+	err = hotp.restoreHashFunc(state.HashFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	hotp.SetIssuer(state.Issuer)
+	hotp.SetOmitIssuerLabel(state.OmitIssuerLabel)
+	hotp.SetHexCounter(state.HexCounter)
+	hotp.SetAutoAdvance(!state.ManualCounter)
+	hotp.EnableTwoStepResync(state.TwoStepResync)
+
+	err = hotp.SetLookAheadWindow(state.LookAheadWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hotp.SetLookBehindWindow(state.LookBehindWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = hotp.SetResyncWindow(state.ResyncWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.TruncationOffset != nil {
+		err = hotp.SetTruncationOffset(*state.TruncationOffset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if state.CounterBytes != 0 {
+		err = hotp.SetCounterBytes(state.CounterBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = hotp.SetMaxFailures(state.MaxFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	hotp.failures = state.Failures
+
+	if state.LastValidatedCounter != nil {
+		hotp.lastValidatedCounter = *state.LastValidatedCounter
+		hotp.hasValidated = true
+	}
+
+	return &hotp, nil
+}
@@ -0,0 +1,103 @@
+package hotp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	original, err := NewHotp(secret,
+		WithDigits(8),
+		WithHashFunc(SHA256),
+		WithLookAhead(3),
+		WithLabel("alice"),
+		WithIssuer("Example"),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, original.SetLookBehindWindow(2))
+	assert.Nil(t, original.SetResyncWindow(1))
+	original.SetHexCounter(true)
+
+	code, err := original.CalculateInt()
+	assert.Nil(t, err)
+
+	validated, err := original.Validate(code)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	state := original.Snapshot()
+	assert.Equal(t, EncodeSecret([]byte(secret)), state.Secret)
+	assert.Equal(t, uint64(1), state.Counter)
+	assert.Equal(t, 8, state.Digits)
+	assert.Equal(t, SHA256, state.HashFunc)
+	assert.Equal(t, 3, state.LookAheadWindow)
+	assert.Equal(t, 2, state.LookBehindWindow)
+	assert.Equal(t, 1, state.ResyncWindow)
+	assert.Equal(t, "alice", state.Label)
+	assert.Equal(t, "Example", state.Issuer)
+	assert.Equal(t, uint64(0), *state.LastValidatedCounter)
+
+	assert.True(t, state.HexCounter)
+	assert.False(t, state.ManualCounter)
+	assert.False(t, state.TwoStepResync)
+
+	restored, err := RestoreHotp(state)
+	assert.Nil(t, err)
+	assert.Equal(t, state, restored.Snapshot())
+	assert.Equal(t, original.GenerateOtpAuth(), restored.GenerateOtpAuth())
+
+	expected, err := original.Calculate()
+	assert.Nil(t, err)
+
+	restoredCode, err := restored.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, restoredCode)
+
+	// auto advance and two step resync survive the round trip as well
+	original.SetAutoAdvance(false)
+	original.EnableTwoStepResync(true)
+
+	state = original.Snapshot()
+	assert.True(t, state.ManualCounter)
+	assert.True(t, state.TwoStepResync)
+
+	restored, err = RestoreHotp(state)
+	assert.Nil(t, err)
+	assert.Equal(t, state, restored.Snapshot())
+
+	validated, err = restored.Validate(code)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	nextCode, err := restored.CalculateInt()
+	assert.Nil(t, err)
+	validated, err = restored.Validate(nextCode)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(1), restored.GetCounter())
+
+	// and through json, where a counter of 0x01 would otherwise come back as 1
+	data, err := json.Marshal(&original)
+	assert.Nil(t, err)
+
+	var unmarshaled Hotp
+	assert.Nil(t, json.Unmarshal(data, &unmarshaled))
+	assert.Equal(t, state, unmarshaled.Snapshot())
+	assert.Contains(t, unmarshaled.GenerateOtpAuth(), "counter=0x")
+}
+
+func TestRestoreHotpInvalid(t *testing.T) {
+	hotp := CreateHotp(secret, 0, 6, "")
+	state := hotp.Snapshot()
+
+	state.HashFunc = HashFunc("md5")
+	_, err := RestoreHotp(state)
+	assert.NotNil(t, err)
+
+	state.HashFunc = SHA1
+	state.Digits = 0
+	_, err = RestoreHotp(state)
+	assert.NotNil(t, err)
+}
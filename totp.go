@@ -0,0 +1,289 @@
+package hotp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeStep = 30
+)
+
+// the time source for ValidateTotp and SyncCounterToTime, replaced in tests
+var timeNow = time.Now
+
+type Totp struct {
+	secret   string
+	digits   int
+	timeStep int
+	epoch    int64
+	skew     int
+	hashFunc HashFunc
+	hasher   func() hash.Hash
+	clock    func() time.Time
+	label    string
+	issuer   string
+
+	replayProtection bool
+	lastStep         uint64
+	hasLastStep      bool
+}
+
+/*
+** creates a totp object with a default hashing algorithm of SHA-1,
+** a time step of 30 seconds and a T0 of 0 (the unix epoch) as described in rfc6238
+ */
+func CreateTotp(secret string, digits int) Totp {
+	return Totp{
+		secret:   secret,
+		digits:   digits,
+		timeStep: defaultTimeStep,
+		epoch:    0,
+		hashFunc: SHA1,
+		hasher:   sha1.New,
+		clock:    time.Now,
+	}
+}
+
+func (totp *Totp) SetTimeStep(seconds int) error {
+	if seconds < 1 {
+		return fmt.Errorf("%w. Got: %d", ErrInvalidTimeStep, seconds)
+	}
+
+	totp.timeStep = seconds
+	return nil
+}
+
+func (totp Totp) GetTimeStep() int {
+	return totp.timeStep
+}
+
+func (totp *Totp) SetLabel(label string) {
+	totp.label = label
+}
+
+func (totp Totp) GetLabel() string {
+	return totp.label
+}
+
+// sets the issuer for this object only, overriding the package issuer from the ISSUER env
+func (totp *Totp) SetIssuer(issuer string) {
+	totp.issuer = issuer
+}
+
+func (totp Totp) GetIssuer() string {
+	if totp.issuer != "" {
+		return totp.issuer
+	}
+
+	return issuer
+}
+
+// sets T0, the unix time to start counting time steps from
+func (totp *Totp) SetEpoch(t int64) {
+	totp.epoch = t
+}
+
+// sets how many time steps before and after the current one Validate accepts, to tolerate clock drift
+func (totp *Totp) SetSkew(steps int) error {
+	err := validateSkew(steps)
+	if err != nil {
+		return err
+	}
+
+	totp.skew = steps
+	return nil
+}
+
+// overrides the time source used to derive the counter. Mostly useful for tests
+func (totp *Totp) SetClock(clock func() time.Time) {
+	totp.clock = clock
+}
+
+func (totp *Totp) SetHashFunc(hashFunc HashFunc) error {
+	hashFunc, err := ParseHashFunc(string(hashFunc))
+	if err != nil {
+		return err
+	}
+
+	err = checkFIPS(hashFunc)
+	if err != nil {
+		return err
+	}
+
+	hasher, err := hasherFor(hashFunc)
+	if err != nil {
+		return err
+	}
+
+	totp.hashFunc = hashFunc
+	totp.hasher = hasher
+	return nil
+}
+
+// counter = (now - T0) / timeStep
+func (totp Totp) counterAt(t time.Time) uint64 {
+	return timeCounter(t.Unix(), totp.epoch, totp.timeStep)
+}
+
+// a clock before T0 is held at counter 0 rather than wrapping around to a huge unsigned counter
+func timeCounter(unix int64, epoch int64, timeStep int) uint64 {
+	if unix < epoch {
+		return 0
+	}
+
+	return uint64((unix - epoch) / int64(timeStep))
+}
+
+// the counter for the current time step, it changes whenever the code rolls over
+func (totp Totp) CurrentCounter() uint64 {
+	return totp.counterAt(totp.clock())
+}
+
+/*
+** how many seconds the current code is still valid for, i.e. timeStep - (now - T0) % timeStep.
+** Before T0 the counter is held at 0, so its code lasts until the first time step ends
+ */
+func (totp Totp) SecondsRemaining() int {
+	elapsed := totp.clock().Unix() - totp.epoch
+	if elapsed < 0 {
+		return totp.timeStep - int(elapsed)
+	}
+
+	return totp.timeStep - int(elapsed%int64(totp.timeStep))
+}
+
+func (totp Totp) Calculate() (string, error) {
+	return totp.CalculateAt(totp.clock())
+}
+
+// the code for the time step t falls in, e.g. for a scheduled event, without touching the clock
+func (totp Totp) CalculateAt(t time.Time) (string, error) {
+	return CalculateCode(totp.secret, totp.counterAt(t), totp.digits, totp.hasher)
+}
+
+// validates the code against the current time step, and skew steps on either side of it
+func (totp *Totp) Validate(code int) (bool, error) {
+	validated, _, err := totp.ValidateWithStep(code)
+	return validated, err
+}
+
+// same as Validate, but also returns the time step (counter) the code matched, e.g. for audit logs
+func (totp *Totp) ValidateWithStep(code int) (bool, uint64, error) {
+	validated, step, err := validateTotpCounter(totp.secret, code, totp.digits, totp.hasher, totp.counterAt(totp.clock()), totp.skew)
+	if err != nil || !validated || !totp.replayProtection {
+		return validated, step, err
+	}
+
+	if totp.hasLastStep && step <= totp.lastStep {
+		return false, 0, nil
+	}
+
+	totp.lastStep = step
+	totp.hasLastStep = true
+	return true, step, nil
+}
+
+/*
+** a totp code stays valid for its whole time step, and the skew steps around it. With replay protection
+** the validate methods remember the highest step they accepted, and reject any code matching that step
+** or an earlier one, so a code can only be used once. Turning it off forgets the last step
+ */
+func (totp *Totp) SetReplayProtection(enabled bool) {
+	totp.replayProtection = enabled
+	if !enabled {
+		totp.lastStep = 0
+		totp.hasLastStep = false
+	}
+}
+
+// the highest step accepted with replay protection on, false when none was accepted yet
+func (totp Totp) GetLastStep() (uint64, bool) {
+	return totp.lastStep, totp.hasLastStep
+}
+
+// the provisioning uri for authenticator apps, the time step is sent as the period parameter
+func (totp Totp) GenerateOtpAuth() string {
+	query := url.Values{}
+	query.Set("secret", EncodeSecret([]byte(totp.secret)))
+	query.Set("algorithm", string(totp.hashFunc))
+	query.Set("digits", strconv.Itoa(totp.digits))
+	query.Set("period", strconv.Itoa(totp.timeStep))
+
+	issuer := totp.GetIssuer()
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", FormatLabel(issuer, totp.label), query.Encode())
+}
+
+/*
+** stateless TOTP validation, the counter is derived from the current unix time with a T0 of 0.
+** skewSteps is how many time steps before and after the current one are accepted, to tolerate
+** clock drift between the client and server. rfc6238 section 5.2 recommends at most 1
+ */
+func ValidateTotp(secret string, code int, digits int, algorithm HashFunc, timeStep int, skewSteps int) (bool, error) {
+	if timeStep < 1 {
+		return false, fmt.Errorf("%w. Got: %d", ErrInvalidTimeStep, timeStep)
+	}
+
+	err := validateSkew(skewSteps)
+	if err != nil {
+		return false, err
+	}
+
+	hasher, err := hasherForCall(algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	counter := timeCounter(timeNow().Unix(), 0, timeStep)
+
+	validated, _, err := validateTotpCounter(secret, code, digits, hasher, counter, skewSteps)
+	return validated, err
+}
+
+func validateSkew(steps int) error {
+	return validateWindow(steps, "skew")
+}
+
+// checks counter first, then the nearest steps around it. Returns the counter that matched
+func validateTotpCounter(secret string, code int, digits int, hasher func() hash.Hash, counter uint64, skew int) (bool, uint64, error) {
+	validated, err := Validate(secret, counter, digits, code, hasher)
+	if err != nil || validated {
+		return validated, counter, err
+	}
+
+	for i := range uint64(skew) {
+		i += 1
+
+		validated, err := Validate(secret, counter+i, digits, code, hasher)
+		if err != nil {
+			return false, 0, err
+		}
+
+		if validated {
+			return true, counter + i, nil
+		}
+
+		if i > counter {
+			continue
+		}
+
+		validated, err = Validate(secret, counter-i, digits, code, hasher)
+		if err != nil {
+			return false, 0, err
+		}
+
+		if validated {
+			return true, counter - i, nil
+		}
+	}
+
+	return false, 0, nil
+}
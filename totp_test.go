@@ -0,0 +1,285 @@
+package hotp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seeds from rfc6238 appendix B
+var totpSecrets = map[HashFunc]string{
+	SHA1:   "12345678901234567890",
+	SHA256: "12345678901234567890123456789012",
+	SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+var totpExpectedCodes = []struct {
+	unixTime int64
+	codes    map[HashFunc]string
+}{
+	{59, map[HashFunc]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+	{1111111109, map[HashFunc]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+	{1111111111, map[HashFunc]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+	{1234567890, map[HashFunc]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+	{2000000000, map[HashFunc]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+	{20000000000, map[HashFunc]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+}
+
+func fixedClock(unixTime int64) func() time.Time {
+	return func() time.Time {
+		return time.Unix(unixTime, 0)
+	}
+}
+
+func TestTotpRFCVectors(t *testing.T) {
+	for _, expected := range totpExpectedCodes {
+		for hashFunc, code := range expected.codes {
+			totp := CreateTotp(totpSecrets[hashFunc], 8)
+			assert.Nil(t, totp.SetHashFunc(hashFunc))
+			totp.SetClock(fixedClock(expected.unixTime))
+
+			calculated, err := totp.Calculate()
+			assert.Nil(t, err)
+
+			assert.Equal(t, code, calculated, "%s at %d", hashFunc, expected.unixTime)
+		}
+	}
+}
+
+func TestTotpCalculateAt(t *testing.T) {
+	for hashFunc, totpSecret := range totpSecrets {
+		totp := CreateTotp(totpSecret, 8)
+		assert.Nil(t, totp.SetHashFunc(hashFunc))
+
+		// the clock isn't used
+		totp.SetClock(fixedClock(0))
+
+		for _, expected := range totpExpectedCodes {
+			code, err := totp.CalculateAt(time.Unix(expected.unixTime, 0))
+			assert.Nil(t, err)
+			assert.Equal(t, expected.codes[hashFunc], code, "%s at %d", hashFunc, expected.unixTime)
+		}
+	}
+}
+
+func TestTotpTimeStepAndEpoch(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(1000 + 59))
+	totp.SetEpoch(1000)
+
+	code, err := totp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "94287082", code)
+
+	// with a 60 second step, 119 seconds lands on the same counter as 59 seconds with 30
+	totp = CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(119))
+	assert.Nil(t, totp.SetTimeStep(60))
+
+	code, err = totp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "94287082", code)
+
+	assert.NotNil(t, totp.SetTimeStep(0))
+}
+
+func TestValidateTotp(t *testing.T) {
+	defer func(original func() time.Time) {
+		timeNow = original
+	}(timeNow)
+
+	// 94287082 is the code for 59 seconds (counter 1), 37359152 for counter 2
+	timeNow = fixedClock(59)
+
+	validated, err := ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 30, 0)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the client is one step ahead of the server
+	validated, err = ValidateTotp(totpSecrets[SHA1], 37359152, 8, SHA1, 30, 0)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = ValidateTotp(totpSecrets[SHA1], 37359152, 8, SHA1, 30, 1)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the client is one step behind the server
+	timeNow = fixedClock(89)
+
+	validated, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 30, 0)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 30, 1)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// two steps of drift is outside a skew of 1
+	timeNow = fixedClock(119)
+
+	validated, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 30, 1)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	_, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 0, 1)
+	assert.NotNil(t, err)
+
+	_, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, SHA1, 30, -1)
+	assert.NotNil(t, err)
+
+	_, err = ValidateTotp(totpSecrets[SHA1], 94287082, 8, HashFunc("md5"), 30, 1)
+	assert.NotNil(t, err)
+}
+
+func TestTotpValidate(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(89))
+
+	validated, err := totp.Validate(94287082)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	assert.Nil(t, totp.SetSkew(1))
+	validated, err = totp.Validate(94287082)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	// the current step at 89 seconds is counter 2
+	validated, err = totp.Validate(37359152)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	assert.NotNil(t, totp.SetSkew(-1))
+}
+
+func TestTotpValidateWithStep(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(89))
+	assert.Nil(t, totp.SetSkew(1))
+
+	// counter 2 is the current step, 1 and 3 are one step of drift either way
+	for step, code := range map[uint64]int{1: 94287082, 2: 37359152, 3: 26969429} {
+		validated, matched, err := totp.ValidateWithStep(code)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+		assert.Equal(t, step, matched)
+	}
+
+	// counter 4 is outside the skew
+	validated, _, err := totp.ValidateWithStep(40338314)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+}
+
+func TestTotpReplayProtection(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetClock(fixedClock(89))
+	assert.Nil(t, totp.SetSkew(1))
+
+	// without it the same code validates for the whole step
+	for range 2 {
+		validated, err := totp.Validate(37359152)
+		assert.Nil(t, err)
+		assert.True(t, validated)
+	}
+
+	totp.SetReplayProtection(true)
+	_, accepted := totp.GetLastStep()
+	assert.False(t, accepted)
+
+	validated, err := totp.Validate(37359152)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	lastStep, accepted := totp.GetLastStep()
+	assert.True(t, accepted)
+	assert.Equal(t, uint64(2), lastStep)
+
+	validated, err = totp.Validate(37359152)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	// an earlier step inside the skew is rejected too, a later one is still fine
+	validated, _, err = totp.ValidateWithStep(94287082)
+	assert.Nil(t, err)
+	assert.False(t, validated)
+
+	validated, step, err := totp.ValidateWithStep(26969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+	assert.Equal(t, uint64(3), step)
+
+	totp.SetReplayProtection(false)
+	_, accepted = totp.GetLastStep()
+	assert.False(t, accepted)
+
+	validated, err = totp.Validate(26969429)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
+
+func TestSecondsRemaining(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+
+	expected := map[int64]int{
+		0:  30,
+		1:  29,
+		29: 1,
+		30: 30,
+		59: 1,
+		75: 15,
+	}
+
+	for unixTime, remaining := range expected {
+		totp.SetClock(fixedClock(unixTime))
+		assert.Equal(t, remaining, totp.SecondsRemaining(), "at %d", unixTime)
+	}
+
+	totp.SetEpoch(10)
+	totp.SetClock(fixedClock(15))
+	assert.Equal(t, 25, totp.SecondsRemaining())
+}
+
+func TestTotpBeforeEpoch(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+	totp.SetEpoch(1000)
+	totp.SetClock(fixedClock(10))
+
+	// held at the first counter instead of wrapping around
+	assert.Equal(t, uint64(0), totp.CurrentCounter())
+	assert.Equal(t, 1020, totp.SecondsRemaining())
+
+	// the rfc4226 secret at counter 0
+	code, err := totp.Calculate()
+	assert.Nil(t, err)
+	assert.Equal(t, "84755224", code)
+
+	validated, err := totp.Validate(84755224)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+
+	defer func(original func() time.Time) {
+		timeNow = original
+	}(timeNow)
+
+	timeNow = fixedClock(-60)
+
+	validated, err = ValidateTotp(totpSecrets[SHA1], 84755224, 8, SHA1, 30, 0)
+	assert.Nil(t, err)
+	assert.True(t, validated)
+}
+
+func TestCurrentCounter(t *testing.T) {
+	totp := CreateTotp(totpSecrets[SHA1], 8)
+
+	totp.SetClock(fixedClock(59))
+	assert.Equal(t, uint64(1), totp.CurrentCounter())
+
+	totp.SetClock(fixedClock(60))
+	assert.Equal(t, uint64(2), totp.CurrentCounter())
+
+	totp.SetClock(fixedClock(1111111109))
+	assert.Equal(t, uint64(0x23523EC), totp.CurrentCounter())
+}
@@ -0,0 +1,28 @@
+package hotp
+
+/*
+** the test secret from rfc4226 appendix D, the ascii bytes "12345678901234567890".
+** It is public and only meant for test fixtures, never use it for a real token
+ */
+const RFC4226TestSecret = "12345678901234567890"
+
+// one row of rfc4226 appendix D, the 6 digit SHA-1 code for Counter with Secret
+type RFC4226Vector struct {
+	Secret  string
+	Counter uint64
+	Code    string
+}
+
+// the published test vectors from rfc4226 appendix D, for checking other implementations against this one
+var RFC4226Vectors = []RFC4226Vector{
+	{RFC4226TestSecret, 0, "755224"},
+	{RFC4226TestSecret, 1, "287082"},
+	{RFC4226TestSecret, 2, "359152"},
+	{RFC4226TestSecret, 3, "969429"},
+	{RFC4226TestSecret, 4, "338314"},
+	{RFC4226TestSecret, 5, "254676"},
+	{RFC4226TestSecret, 6, "287922"},
+	{RFC4226TestSecret, 7, "162583"},
+	{RFC4226TestSecret, 8, "399871"},
+	{RFC4226TestSecret, 9, "520489"},
+}
@@ -0,0 +1,18 @@
+package hotp
+
+import (
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRFC4226Vectors(t *testing.T) {
+	assert.Len(t, RFC4226Vectors, 10)
+
+	for _, vector := range RFC4226Vectors {
+		code, err := CalculateCode(vector.Secret, vector.Counter, 6, sha1.New)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.Code, code, "counter %d", vector.Counter)
+	}
+}